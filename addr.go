@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"strconv"
 )
 
@@ -169,3 +170,55 @@ func (o *ResponseOrigin) GetFrom(m *Message) error {
 func (o ResponseOrigin) String() string {
 	return net.JoinHostPort(o.IP.String(), strconv.Itoa(o.Port))
 }
+
+// GetAddrPort decodes MAPPED-ADDRESS value of attribute t in message m,
+// e.g. AttrMappedAddress or AttrOtherAddress.
+//
+// Unlike GetFrom, it does not allocate and returned value does not alias
+// message memory, so it is suitable for per-packet hot paths.
+func GetAddrPort(m *Message, t AttrType) (netip.AddrPort, error) {
+	value, err := m.Get(t)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	return decodeAddrPort(t, value, nil)
+}
+
+// decodeAddrPort decodes MAPPED-ADDRESS encoded value of attribute t,
+// xoring port and address with key if it is not nil.
+func decodeAddrPort(t AttrType, value []byte, key *[net.IPv6len]byte) (netip.AddrPort, error) {
+	if len(value) <= 4 {
+		return netip.AddrPort{}, io.ErrUnexpectedEOF
+	}
+	family := bin.Uint16(value[0:2])
+	if family != familyIPv6 && family != familyIPv4 {
+		return netip.AddrPort{}, newDecodeErr("mapped address", "family",
+			fmt.Sprintf("bad value %d", family),
+		)
+	}
+	ipLen := net.IPv4len
+	if family == familyIPv6 {
+		ipLen = net.IPv6len
+	}
+	if len(value[4:]) < ipLen {
+		return netip.AddrPort{}, io.ErrUnexpectedEOF
+	}
+	if err := CheckOverflow(t, len(value[4:]), ipLen); err != nil {
+		return netip.AddrPort{}, err
+	}
+	var ip [net.IPv6len]byte
+	copy(ip[:], value[4:])
+	port := bin.Uint16(value[2:4])
+	if key != nil {
+		port ^= bin.Uint16(key[0:2])
+		for i := 0; i < ipLen; i++ {
+			ip[i] ^= key[i]
+		}
+	}
+	if family == familyIPv4 {
+		return netip.AddrPortFrom(netip.AddrFrom4([net.IPv4len]byte(ip[:net.IPv4len])), port), nil
+	}
+
+	return netip.AddrPortFrom(netip.AddrFrom16(ip), port), nil
+}
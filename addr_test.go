@@ -7,7 +7,10 @@ import (
 	"errors"
 	"io"
 	"net"
+	"net/netip"
 	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 func TestMappedAddress(t *testing.T) {
@@ -176,3 +179,51 @@ func BenchmarkAlternateServer_AddTo(b *testing.B) {
 		m.Reset()
 	}
 }
+
+func TestGetAddrPort(t *testing.T) {
+	for _, s := range []string{"122.12.34.5:5412", "[2001:db8::1]:3478"} {
+		expected := netip.MustParseAddrPort(s)
+		addr := &MappedAddress{IP: expected.Addr().AsSlice(), Port: int(expected.Port())}
+		msg := MustBuild(addr)
+		var (
+			got netip.AddrPort
+			err error
+		)
+		testutil.ShouldNotAllocate(t, func() {
+			got, err = GetAddrPort(msg, AttrMappedAddress)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Errorf("got %s, expected %s", got, expected)
+		}
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		msg := new(Message)
+		if _, err := GetAddrPort(msg, AttrMappedAddress); !errors.Is(err, ErrAttributeNotFound) {
+			t.Errorf("unexpected error %v", err)
+		}
+		for _, tc := range []struct {
+			value []byte
+			err   error
+		}{
+			{[]byte{0, 1, 0, 1}, io.ErrUnexpectedEOF},
+			{[]byte{0, 1, 0, 1, 1, 2}, io.ErrUnexpectedEOF},
+			{[]byte{0, 2, 0, 1, 1, 2, 3, 4}, io.ErrUnexpectedEOF},
+			{[]byte{0, 1, 0, 1, 1, 2, 3, 4, 5}, ErrAttributeSizeOverflow},
+		} {
+			msg.Reset()
+			msg.Add(AttrMappedAddress, tc.value)
+			if _, err := GetAddrPort(msg, AttrMappedAddress); !errors.Is(err, tc.err) {
+				t.Errorf("%v: unexpected error %v", tc.value, err)
+			}
+		}
+		msg.Reset()
+		msg.Add(AttrMappedAddress, []byte{0, 3, 0, 1, 1, 2, 3, 4})
+		var decodeErr *DecodeErr
+		if _, err := GetAddrPort(msg, AttrMappedAddress); !errors.As(err, &decodeErr) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
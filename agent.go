@@ -6,6 +6,7 @@ package stun
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,18 +17,39 @@ func NoopHandler() Handler {
 
 // NewAgent initializes and returns new Agent with provided handler.
 // If h is nil, the NoopHandler will be used.
-func NewAgent(h Handler) *Agent {
+func NewAgent(h Handler, opts ...AgentOption) *Agent {
 	if h == nil {
 		h = NoopHandler()
 	}
 	a := &Agent{
 		transactions: make(map[transactionID]agentTransaction),
 		handler:      h,
+		expired:      make([]transactionID, 0, agentCollectCap),
+		recent:       newRecentTransactions(agentRecentCap),
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
 
 	return a
 }
 
+// AgentOption sets some property of Agent.
+type AgentOption func(a *Agent)
+
+// WithCollectWorkers sets maximum number of goroutines that call handler
+// for timed out transactions in Collect, so slow handler does not delay
+// timeouts of other transactions: each goroutine takes next timed out
+// transaction when its handler returns.
+//
+// Collect still blocks until all handlers return. Values less than 2 mean
+// that handlers are called serially from Collect, which is the default.
+func WithCollectWorkers(n int) AgentOption {
+	return func(a *Agent) {
+		a.collectWorkers = n
+	}
+}
+
 // Agent is low-level abstraction over transaction list that
 // handles concurrency (all calls are goroutine-safe) and
 // time outs (via Collect call).
@@ -38,9 +60,71 @@ type Agent struct {
 	// minimizing mux lock and protecting agentTransaction from
 	// data races via unexpected concurrent access.
 	transactions map[transactionID]agentTransaction
-	closed       bool       // all calls are invalid if true
-	mux          sync.Mutex // protects transactions and closed
-	handler      Handler    // handles transactions
+	closed       bool            // all calls are invalid if true
+	mux          sync.Mutex      // protects transactions, closed, expired and recent
+	handler      Handler         // handles transactions
+	expired      []transactionID // scratch slice for Collect
+	recent       recentTransactions
+	duplicates   atomic.Uint64 // responses to transactions with response
+	late         atomic.Uint64 // responses to timed out or stopped transactions
+
+	collectWorkers int // see WithCollectWorkers
+}
+
+// AgentStats is a snapshot of Agent counters.
+type AgentStats struct {
+	// DuplicateResponses is number of responses to recently completed
+	// transactions that already got response, e.g. due to network
+	// duplication or reflection.
+	DuplicateResponses uint64
+	// LateResponses is number of responses to recently timed out or
+	// stopped transactions, which are passed to handler.
+	LateResponses uint64
+}
+
+// Stats returns snapshot of agent counters.
+func (a *Agent) Stats() AgentStats {
+	return AgentStats{
+		DuplicateResponses: a.duplicates.Load(),
+		LateResponses:      a.late.Load(),
+	}
+}
+
+// agentRecentCap is number of recently completed transactions that are
+// remembered to detect duplicate and late responses.
+const agentRecentCap = 1024
+
+// recentTransactions is fixed size set of recently completed transactions,
+// evicting the oldest one on overflow.
+type recentTransactions struct {
+	ids       []transactionID
+	next      int
+	responded map[transactionID]bool
+}
+
+func newRecentTransactions(size int) recentTransactions {
+	return recentTransactions{
+		ids:       make([]transactionID, 0, size),
+		responded: make(map[transactionID]bool, size),
+	}
+}
+
+// add remembers completed transaction id, responded is true if
+// transaction is completed with response.
+func (r *recentTransactions) add(id transactionID, responded bool) {
+	if _, ok := r.responded[id]; ok {
+		r.responded[id] = responded
+
+		return
+	}
+	if len(r.ids) < cap(r.ids) {
+		r.ids = append(r.ids, id)
+	} else {
+		delete(r.responded, r.ids[r.next])
+		r.ids[r.next] = id
+		r.next = (r.next + 1) % len(r.ids)
+	}
+	r.responded[id] = responded
 }
 
 // Handler handles state changes of transaction.
@@ -56,6 +140,26 @@ type Event struct {
 	TransactionID [TransactionIDSize]byte
 	Message       *Message
 	Error         error
+	// RTT is time from the first request to response, including
+	// retransmissions. Set by Client for transactions completed with
+	// response, zero otherwise.
+	RTT time.Duration
+	// AttemptRTT is time from the last request (re)transmission to
+	// response. Equals RTT if there were no retransmissions, otherwise
+	// the response can belong to earlier attempt, so it is not suitable
+	// as RTT sample (see Karn's algorithm).
+	AttemptRTT time.Duration
+	// ReceivedAt is kernel receive timestamp of response, set by Client
+	// if enabled by WithKernelTimestamps and available, zero otherwise.
+	ReceivedAt time.Time
+	// ErrorCode, Realm, Nonce and AlternateServer are set by Client from
+	// error response if present, so handlers of authentication (401, 438)
+	// or redirection (300) do not have to parse Message. Like Message,
+	// they are valid only during Handler call.
+	ErrorCode       *ErrorCodeAttribute
+	Realm           Realm
+	Nonce           Nonce
+	AlternateServer *AlternateServer
 }
 
 // agentTransaction represents transaction in progress.
@@ -86,6 +190,9 @@ func (a *Agent) StopWithError(id [TransactionIDSize]byte, err error) error {
 	}
 	t, exists := a.transactions[id]
 	delete(a.transactions, id)
+	if exists {
+		a.recent.add(id, false)
+	}
 	h := a.handler
 	a.mux.Unlock()
 	if !exists {
@@ -131,12 +238,39 @@ func (a *Agent) Start(id [TransactionIDSize]byte, deadline time.Time) error {
 	return nil
 }
 
-// agentCollectCap is initial capacity for Agent.Collect slices,
+// SetDeadline sets deadline of pending transaction with provided id, e.g.
+// to extend it without stopping and restarting transaction. Use
+// Client.SetDeadline for transactions of Client, which also reschedules
+// its timer if precise timeouts are enabled.
+// Could return ErrAgentClosed, ErrTransactionNotExists.
+func (a *Agent) SetDeadline(id [TransactionIDSize]byte, deadline time.Time) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.closed {
+		return ErrAgentClosed
+	}
+	t, exists := a.transactions[id]
+	if !exists {
+		return ErrTransactionNotExists
+	}
+	t.deadline = deadline
+	a.transactions[id] = t
+
+	return nil
+}
+
+// agentCollectCap is initial capacity for Agent.Collect scratch slice,
 // sufficient to make function zero-alloc in most cases.
 const agentCollectCap = 100
 
 // ErrTransactionTimeOut indicates that transaction has reached deadline.
-var ErrTransactionTimeOut = errors.New("transaction is timed out")
+//
+// Implements net.Error with Timeout() and Temporary() returning true.
+var ErrTransactionTimeOut error = &netError{ //nolint:gochecknoglobals
+	msg:       "transaction is timed out",
+	timeout:   true,
+	temporary: true,
+}
 
 // Collect terminates all transactions that have deadline before provided
 // time, blocking until all handlers will process ErrTransactionTimeOut.
@@ -144,7 +278,6 @@ var ErrTransactionTimeOut = errors.New("transaction is timed out")
 //
 // It is safe to call Collect concurrently but makes no sense.
 func (a *Agent) Collect(gcTime time.Time) error {
-	toRemove := make([]transactionID, 0, agentCollectCap)
 	a.mux.Lock()
 	if a.closed {
 		// Doing nothing if agent is closed.
@@ -155,36 +288,89 @@ func (a *Agent) Collect(gcTime time.Time) error {
 		return ErrAgentClosed
 	}
 	// Adding all transactions with deadline before gcTime
-	// to toCall and toRemove slices.
-	// No allocs if there are less than agentCollectCap
-	// timed out transactions.
+	// to toRemove slice, which is reused between calls,
+	// so no allocs if nothing timed out.
+	toRemove := a.expired[:0]
 	for id, t := range a.transactions {
 		if t.deadline.Before(gcTime) {
 			toRemove = append(toRemove, id)
 		}
 	}
+	if len(toRemove) == 0 {
+		a.expired = toRemove
+		a.mux.Unlock()
+
+		return nil
+	}
 	// Un-registering timed out transactions.
 	for _, id := range toRemove {
 		delete(a.transactions, id)
+		a.recent.add(id, false)
 	}
+	// Detaching scratch slice from agent, so concurrent
+	// Collect call will not overwrite it.
+	a.expired = nil
 	// Calling handler does not require locked mutex,
 	// reducing lock time.
 	h := a.handler
 	a.mux.Unlock()
 	// Sending ErrTransactionTimeOut to handler for all transactions,
 	// blocking until last one.
-	event := Event{
-		Error: ErrTransactionTimeOut,
+	if a.collectWorkers > 1 && len(toRemove) > 1 {
+		dispatchTimeouts(h, toRemove, a.collectWorkers)
+	} else {
+		event := Event{
+			Error: ErrTransactionTimeOut,
+		}
+		for _, id := range toRemove {
+			event.TransactionID = id
+			h(event)
+		}
 	}
-	for _, id := range toRemove {
-		event.TransactionID = id
-		h(event)
+	a.mux.Lock()
+	if a.expired == nil {
+		a.expired = toRemove[:0]
 	}
+	a.mux.Unlock()
 
 	return nil
 }
 
+// dispatchTimeouts calls h with ErrTransactionTimeOut for ids on at most
+// workers goroutines, blocking until all calls return. Goroutines take ids
+// from shared queue, so slow handler blocks only its own goroutine. Each id
+// appears in ids once, so there is no ordering to preserve.
+func dispatchTimeouts(h Handler, ids []transactionID, workers int) {
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+	var (
+		wg   sync.WaitGroup
+		next atomic.Int64 // index of next id in ids
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			event := Event{
+				Error: ErrTransactionTimeOut,
+			}
+			for i := next.Add(1) - 1; i < int64(len(ids)); i = next.Add(1) - 1 {
+				event.TransactionID = ids[i]
+				h(event)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // Process incoming message, synchronously passing it to handler.
+//
+// Responses to recently completed transactions that already got response
+// are counted as duplicate (see Stats) and discarded. Responses to recently
+// timed out or stopped transactions are counted as late, but are still
+// passed to handler, as transaction can be in progress in the handler, e.g.
+// Client starts retransmission after timeout.
 func (a *Agent) Process(m *Message) error {
 	event := Event{
 		TransactionID: m.TransactionID,
@@ -197,13 +383,30 @@ func (a *Agent) Process(m *Message) error {
 		return ErrAgentClosed
 	}
 	h := a.handler
-	delete(a.transactions, m.TransactionID)
+	id := transactionID(m.TransactionID)
+	if _, exists := a.transactions[id]; exists {
+		delete(a.transactions, id)
+		a.recent.add(id, true)
+	} else if responded, recent := a.recent.responded[id]; recent && isResponse(m.Type.Class) {
+		if responded {
+			a.duplicates.Add(1)
+			a.mux.Unlock()
+
+			return nil
+		}
+		a.late.Add(1)
+		a.recent.add(id, true)
+	}
 	a.mux.Unlock()
 	h(event)
 
 	return nil
 }
 
+func isResponse(c MessageClass) bool {
+	return c == ClassSuccessResponse || c == ClassErrorResponse
+}
+
 // SetHandler sets agent handler to h.
 func (a *Agent) SetHandler(h Handler) error {
 	a.mux.Lock()
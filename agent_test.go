@@ -5,8 +5,11 @@ package stun
 
 import (
 	"errors"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 func TestAgent_ProcessInTransaction(t *testing.T) {
@@ -171,6 +174,164 @@ func TestAgent_GC(t *testing.T) { //nolint:cyclop
 	}
 }
 
+func TestAgent_SetDeadline(t *testing.T) {
+	var timedOut []transactionID
+	agent := NewAgent(func(e Event) {
+		if errors.Is(e.Error, ErrTransactionTimeOut) {
+			timedOut = append(timedOut, e.TransactionID)
+		}
+	})
+	deadline := time.Now()
+	id := NewTransactionID()
+	if err := agent.SetDeadline(id, deadline); !errors.Is(err, ErrTransactionNotExists) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := agent.Start(id, deadline); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.SetDeadline(id, deadline.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Collect(deadline.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(timedOut) != 0 {
+		t.Fatal("extended transaction should not time out")
+	}
+	if err := agent.Collect(deadline.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(timedOut) != 1 || timedOut[0] != id {
+		t.Errorf("unexpected timed out transactions %x", timedOut)
+	}
+	if err := agent.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := agent.SetDeadline(id, deadline); !errors.Is(err, ErrAgentClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAgent_CollectScratch(t *testing.T) {
+	timedOut := 0
+	agent := NewAgent(func(e Event) {
+		if errors.Is(e.Error, ErrTransactionTimeOut) {
+			timedOut++
+		}
+	})
+	deadline := time.Now().AddDate(0, 0, 1)
+	for i := 0; i < agentCollectCap*2; i++ {
+		if err := agent.Start(NewTransactionID(), deadline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("Idle", func(t *testing.T) {
+		testutil.ShouldNotAllocate(t, func() {
+			if err := agent.Collect(deadline.Add(-time.Second)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+	if err := agent.Collect(deadline.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if timedOut != agentCollectCap*2 {
+		t.Errorf("timed out %d, expected %d", timedOut, agentCollectCap*2)
+	}
+	if cap(agent.expired) < agentCollectCap*2 {
+		t.Errorf("scratch slice is not reused, cap %d", cap(agent.expired))
+	}
+	if err := agent.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAgent_DuplicateResponses(t *testing.T) {
+	handled := 0
+	agent := NewAgent(func(Event) {
+		handled++
+	})
+	deadline := time.Now().Add(time.Second)
+	var (
+		responded = MustBuild(TransactionID, BindingSuccess)
+		timedOut  = MustBuild(TransactionID, BindingError)
+		unknown   = MustBuild(TransactionID, BindingSuccess)
+	)
+	for _, m := range []*Message{responded, timedOut} {
+		if err := agent.Start(m.TransactionID, deadline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := agent.Process(responded); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Stop(timedOut.TransactionID); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*Message{responded, responded, timedOut, timedOut, unknown} {
+		if err := agent.Process(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Response and stop event, late response, unknown transaction.
+	if handled != 4 {
+		t.Errorf("handled %d events, expected 4", handled)
+	}
+	// Second late response is duplicate.
+	expected := AgentStats{DuplicateResponses: 3, LateResponses: 1}
+	if stats := agent.Stats(); stats != expected {
+		t.Errorf("stats %+v, expected %+v", stats, expected)
+	}
+	if err := agent.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAgent_LateResponse(t *testing.T) {
+	var events []Event
+	agent := NewAgent(func(e Event) {
+		events = append(events, e)
+	})
+	deadline := time.Now().Add(time.Second)
+	m := MustBuild(TransactionID, BindingSuccess)
+	if err := agent.Start(m.TransactionID, deadline); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Collect(deadline.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// Response can arrive before Client restarts transaction for
+	// retransmission, so it must not be discarded.
+	if err := agent.Process(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !errors.Is(events[0].Error, ErrTransactionTimeOut) || events[1].Message != m {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if stats := agent.Stats(); stats.LateResponses != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if err := agent.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecentTransactions(t *testing.T) {
+	r := newRecentTransactions(2)
+	ids := []transactionID{{1}, {2}, {3}}
+	for _, id := range ids {
+		r.add(id, true)
+	}
+	if _, ok := r.responded[ids[0]]; ok {
+		t.Error("oldest transaction should be evicted")
+	}
+	for _, id := range ids[1:] {
+		if _, ok := r.responded[id]; !ok {
+			t.Errorf("%x should be remembered", id)
+		}
+	}
+}
+
 func BenchmarkAgent_GC(b *testing.B) {
 	agent := NewAgent(nil)
 	deadline := time.Now().AddDate(0, 0, 1)
@@ -214,3 +375,52 @@ func BenchmarkAgent_Process(b *testing.B) {
 		}
 	}
 }
+
+func TestAgent_CollectWorkers(t *testing.T) {
+	const others = 10
+	var (
+		slow    transactionID
+		release = make(chan struct{})
+		mux     sync.Mutex
+		handled = map[transactionID]int{}
+	)
+	agent := NewAgent(func(e Event) {
+		if e.TransactionID == slow {
+			select {
+			case <-release:
+			case <-time.After(time.Second):
+				t.Error("other transactions are blocked by slow handler")
+			}
+
+			return
+		}
+		mux.Lock()
+		handled[e.TransactionID]++
+		if len(handled) == others {
+			close(release)
+		}
+		mux.Unlock()
+	}, WithCollectWorkers(2))
+	deadline := time.Now()
+	if err := agent.Start(slow, deadline); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < others; i++ {
+		var id transactionID
+		id[0] = byte(i + 1)
+		if err := agent.Start(id, deadline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := agent.Collect(deadline.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for id, n := range handled {
+		if n != 1 {
+			t.Errorf("%x handled %d times", id, n)
+		}
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
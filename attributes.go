@@ -4,8 +4,9 @@
 package stun
 
 import (
+	"encoding/hex"
 	"errors"
-	"fmt"
+	"strconv"
 )
 
 // Attributes is list of message attributes.
@@ -125,6 +126,14 @@ func (t AttrType) Value() uint16 {
 	return uint16(t)
 }
 
+// AttrTypeNames returns registry of known attribute type names,
+// e.g. "MAPPED-ADDRESS" for AttrMappedAddress.
+//
+// Returned map is a copy, so modifying it does not affect AttrType.String.
+func AttrTypeNames() map[AttrType]string {
+	return attrNames()
+}
+
 func attrNames() map[AttrType]string {
 	return map[AttrType]string{
 		AttrMappedAddress:          "MAPPED-ADDRESS",
@@ -165,7 +174,7 @@ func (t AttrType) String() string {
 	s, ok := attrNames()[t]
 	if !ok {
 		// Just return hex representation of unknown attribute type.
-		return fmt.Sprintf("0x%x", uint16(t))
+		return "0x" + strconv.FormatUint(uint64(t), 16)
 	}
 
 	return s
@@ -182,6 +191,9 @@ type RawAttribute struct {
 	Type   AttrType
 	Length uint16 // ignored while encoding
 	Value  []byte
+	// Offset is position of attribute header (type) in Message.Raw,
+	// set by Message.Decode and Message.Add and ignored while encoding.
+	Offset int
 }
 
 // AddTo implements Setter, adding attribute as a.Type with a.Value and ignoring
@@ -213,7 +225,7 @@ func (a RawAttribute) Equal(b RawAttribute) bool {
 }
 
 func (a RawAttribute) String() string {
-	return fmt.Sprintf("%s: 0x%x", a.Type, a.Value)
+	return a.Type.String() + ": 0x" + hex.EncodeToString(a.Value)
 }
 
 // ErrAttributeNotFound means that attribute with provided attribute
@@ -224,12 +236,12 @@ var ErrAttributeNotFound = errors.New("attribute not found")
 // if there is no attribute with such type,
 // ErrAttributeNotFound is returned.
 func (m *Message) Get(t AttrType) ([]byte, error) {
-	v, ok := m.Attributes.Get(t)
-	if !ok {
+	i := m.attrIndex(t)
+	if i < 0 {
 		return nil, ErrAttributeNotFound
 	}
 
-	return v.Value, nil
+	return m.Attributes[i].Value, nil
 }
 
 // STUN aligns attributes on 32-bit boundaries, attributes whose content
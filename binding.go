@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+// BindingRequestOption configures request built by NewBindingRequest.
+type BindingRequestOption func(o *bindingRequestOptions)
+
+type bindingRequestOptions struct {
+	attributes  []Setter
+	username    Setter
+	integrity   Setter
+	fingerprint bool
+}
+
+// WithAttributes adds attributes to binding request, e.g. SOFTWARE or
+// ICE-specific ones. They are placed before USERNAME.
+func WithAttributes(setters ...Setter) BindingRequestOption {
+	return func(o *bindingRequestOptions) {
+		o.attributes = append(o.attributes, setters...)
+	}
+}
+
+// WithShortTermAuth adds USERNAME and MESSAGE-INTEGRITY with short-term
+// credentials to binding request. Password must be SASL-prepared.
+func WithShortTermAuth(username, password string) BindingRequestOption {
+	return func(o *bindingRequestOptions) {
+		o.username = NewUsername(username)
+		o.integrity = NewShortTermIntegrity(password)
+	}
+}
+
+// WithFingerprint adds FINGERPRINT to binding request.
+func WithFingerprint() BindingRequestOption {
+	return func(o *bindingRequestOptions) {
+		o.fingerprint = true
+	}
+}
+
+// NewBindingRequest returns binding request with random transaction id,
+// applying options in order required by RFC 5389: other attributes,
+// USERNAME, MESSAGE-INTEGRITY and FINGERPRINT last, regardless of order
+// of opts.
+func NewBindingRequest(opts ...BindingRequestOption) (*Message, error) {
+	var o bindingRequestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	setters := make([]Setter, 0, len(o.attributes)+5)
+	setters = append(setters, TransactionID, BindingRequest)
+	setters = append(setters, o.attributes...)
+	if o.username != nil {
+		setters = append(setters, o.username)
+	}
+	if o.integrity != nil {
+		setters = append(setters, o.integrity)
+	}
+	if o.fingerprint {
+		setters = append(setters, Fingerprint)
+	}
+
+	return Build(setters...)
+}
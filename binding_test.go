@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewBindingRequest(t *testing.T) {
+	m, err := NewBindingRequest(
+		WithFingerprint(),
+		WithShortTermAuth("user", "pass"),
+		WithAttributes(NewSoftware("software")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != BindingRequest {
+		t.Errorf("unexpected type %s", m.Type)
+	}
+	expected := []AttrType{AttrSoftware, AttrUsername, AttrMessageIntegrity, AttrFingerprint}
+	if len(m.Attributes) != len(expected) {
+		t.Fatalf("unexpected attributes: %s", m.Attributes)
+	}
+	for i, a := range m.Attributes {
+		if a.Type != expected[i] {
+			t.Errorf("[%d]: %s, expected %s", i, a.Type, expected[i])
+		}
+	}
+	decoded := new(Message)
+	if err = Decode(m.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err = decoded.Check(NewShortTermIntegrity("pass"), Fingerprint); err != nil {
+		t.Error(err)
+	}
+	var username Username
+	if err = username.GetFrom(decoded); err != nil || username.String() != "user" {
+		t.Errorf("unexpected USERNAME %q: %v", username, err)
+	}
+	t.Run("Empty", func(t *testing.T) {
+		m, err := NewBindingRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != BindingRequest || len(m.Attributes) != 0 {
+			t.Errorf("unexpected message %s", m)
+		}
+		if m.TransactionID == [TransactionIDSize]byte{} {
+			t.Error("transaction id is not set")
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		if _, err := NewBindingRequest(WithAttributes(errReturner{Err: errTError})); !errors.Is(err, errTError) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Message size bounds for unknown path MTU, RFC 8489 Section 6.1: 576 bytes
+// for IPv4 and 1280 bytes for IPv6 minus IP and UDP headers.
+const (
+	MaxSafeMessageSizeIPv4 = 576 - 20 - 8  // 548
+	MaxSafeMessageSizeIPv6 = 1280 - 40 - 8 // 1232
+)
+
+// ErrSizeBudgetExceeded means that message exceeds size budget.
+var ErrSizeBudgetExceeded = errors.New("message size exceeds budget")
+
+// SizeBudgetErr is returned by BuildWithBudget when estimated message size
+// exceeds budget.
+//
+//nolint:errname
+type SizeBudgetErr struct {
+	Index  int    // index of setter after which budget was exceeded
+	Setter Setter // setter after which budget was exceeded
+	Size   int    // estimated final size of message
+	Budget int
+}
+
+func (e *SizeBudgetErr) Error() string {
+	return fmt.Sprintf("%s: %d > %d bytes after setter %d (%T)",
+		ErrSizeBudgetExceeded, e.Size, e.Budget, e.Index, e.Setter,
+	)
+}
+
+// Unwrap returns ErrSizeBudgetExceeded.
+func (e *SizeBudgetErr) Unwrap() error {
+	return ErrSizeBudgetExceeded
+}
+
+// BuildWithBudget is like Build, but also checks that message will fit
+// into budget bytes, e.g. MaxSafeMessageSizeIPv4 or path MTU minus IP and
+// UDP headers, returning *SizeBudgetErr otherwise.
+//
+// Size is estimated after each setter as current size plus size of the
+// remaining MESSAGE-INTEGRITY and FINGERPRINT setters, so the error points
+// to setter that caused overflow, e.g. large PADDING or DATA. The message
+// is built up to that setter.
+func (m *Message) BuildWithBudget(budget int, setters ...Setter) error {
+	m.Reset()
+	m.WriteHeader()
+	reserved := 0
+	for _, s := range setters {
+		reserved += trailerSize(s)
+	}
+	for i, s := range setters {
+		if err := s.AddTo(m); err != nil {
+			return err
+		}
+		reserved -= trailerSize(s)
+		if size := len(m.Raw) + reserved; size > budget {
+			return &SizeBudgetErr{Index: i, Setter: s, Size: size, Budget: budget}
+		}
+	}
+
+	return nil
+}
+
+// trailerSize returns encoded size of s if it is MESSAGE-INTEGRITY or
+// FINGERPRINT setter, which are usually added last, otherwise 0.
+func trailerSize(s Setter) int {
+	switch s.(type) {
+	case MessageIntegrity:
+		return attributeHeaderSize + messageIntegritySize
+	case FingerprintAttr, *FingerprintAttr:
+		return attributeHeaderSize + fingerprintSize
+	default:
+		return 0
+	}
+}
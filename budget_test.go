@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMessage_BuildWithBudget(t *testing.T) {
+	integrity := NewShortTermIntegrity("pwd")
+	t.Run("Fits", func(t *testing.T) {
+		m := new(Message)
+		if err := m.BuildWithBudget(MaxSafeMessageSizeIPv4,
+			TransactionID, BindingRequest, NewSoftware("software"), integrity, Fingerprint,
+		); err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Raw) != 20+12+24+8 {
+			t.Errorf("unexpected size %d", len(m.Raw))
+		}
+	})
+	t.Run("Exact", func(t *testing.T) {
+		m := new(Message)
+		// Header, attribute and trailers are 20+8+24+8 bytes.
+		if err := m.BuildWithBudget(60,
+			TransactionID, BindingRequest, RawAttribute{Type: AttrData, Value: make([]byte, 4)},
+			integrity, Fingerprint,
+		); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Exceeds", func(t *testing.T) {
+		m := new(Message)
+		padding := RawAttribute{Type: AttrPadding, Value: make([]byte, 512)}
+		err := m.BuildWithBudget(MaxSafeMessageSizeIPv4,
+			TransactionID, BindingRequest, padding, integrity, Fingerprint,
+		)
+		if !errors.Is(err, ErrSizeBudgetExceeded) {
+			t.Fatalf("unexpected error %v", err)
+		}
+		var budgetErr *SizeBudgetErr
+		if !errors.As(err, &budgetErr) {
+			t.Fatal("not SizeBudgetErr")
+		}
+		if budgetErr.Index != 2 {
+			t.Errorf("unexpected index %d", budgetErr.Index)
+		}
+		if budgetErr.Size != 20+4+512+24+8 {
+			t.Errorf("unexpected size %d", budgetErr.Size)
+		}
+		if budgetErr.Error() == "" {
+			t.Error("empty error")
+		}
+	})
+	t.Run("SetterError", func(t *testing.T) {
+		m := new(Message)
+		if err := m.BuildWithBudget(MaxSafeMessageSizeIPv4,
+			errReturner{Err: errTError},
+		); !errors.Is(err, errTError) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
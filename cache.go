@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultResponseCacheTTL is recommended time to keep responses, equal to
+// transaction timeout, see RFC 8489 Section 6.3.1.
+const DefaultResponseCacheTTL = 40 * time.Second
+
+// ResponseCache stores responses by request source address and transaction
+// id, so retransmitted requests can be answered with identical response
+// without running authentication and handlers again, as required for
+// non-idempotent requests by RFC 8489 Section 6.3.1.
+//
+// Expired responses are removed lazily on Put. It is safe to use
+// ResponseCache concurrently.
+type ResponseCache struct {
+	ttl       time.Duration
+	clock     Clock
+	mux       sync.Mutex // guards entries and lastSweep
+	entries   map[responseCacheKey]responseCacheEntry
+	lastSweep time.Time
+}
+
+type responseCacheKey struct {
+	addr string
+	id   transactionID
+}
+
+type responseCacheEntry struct {
+	raw      []byte
+	deadline time.Time
+}
+
+// NewResponseCache initializes and returns new ResponseCache that keeps
+// responses for ttl. If ttl is not positive, DefaultResponseCacheTTL is used.
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	if ttl <= 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+
+	return &ResponseCache{
+		ttl:     ttl,
+		clock:   systemClock(),
+		entries: make(map[responseCacheKey]responseCacheEntry),
+	}
+}
+
+// Get returns cached response to request with transaction id from addr.
+// The returned slice must not be modified.
+func (c *ResponseCache) Get(addr net.Addr, id [TransactionIDSize]byte) ([]byte, bool) {
+	now := c.clock.Now()
+	c.mux.Lock()
+	e, ok := c.entries[responseCacheKey{addr: addr.String(), id: id}]
+	c.mux.Unlock()
+	if !ok || !now.Before(e.deadline) {
+		return nil, false
+	}
+
+	return e.raw, true
+}
+
+// Put stores copy of response m to request from addr. Transaction id of
+// m is used as key.
+func (c *ResponseCache) Put(addr net.Addr, m *Message) {
+	var (
+		now = c.clock.Now()
+		key = responseCacheKey{addr: addr.String(), id: m.TransactionID}
+		raw = append([]byte(nil), m.Raw...)
+	)
+	c.mux.Lock()
+	if now.Sub(c.lastSweep) >= c.ttl {
+		// Amortizing removal of expired responses to once per ttl.
+		for k, e := range c.entries {
+			if !now.Before(e.deadline) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[key] = responseCacheEntry{
+		raw:      raw,
+		deadline: now.Add(c.ttl),
+	}
+	c.mux.Unlock()
+}
+
+// Len returns number of cached responses, including expired ones that are
+// not removed yet.
+func (c *ResponseCache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return len(c.entries)
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	clock := &manualClock{current: time.Now()}
+	cache := NewResponseCache(time.Second)
+	cache.clock = clock
+	var (
+		addr    = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3478}
+		other   = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3479}
+		request = MustBuild(TransactionID, BindingRequest)
+	)
+	if _, ok := cache.Get(addr, request.TransactionID); ok {
+		t.Fatal("empty cache should not return response")
+	}
+	response := MustBuild(request, BindingSuccess, NewSoftware("software"))
+	cache.Put(addr, response)
+	raw, ok := cache.Get(addr, request.TransactionID)
+	if !ok {
+		t.Fatal("response should be cached")
+	}
+	if !bytes.Equal(raw, response.Raw) {
+		t.Errorf("%x != %x", raw, response.Raw)
+	}
+	response.Raw[0] = 0xff
+	if raw[0] == 0xff {
+		t.Error("response should be copied")
+	}
+	if _, ok = cache.Get(other, request.TransactionID); ok {
+		t.Error("response should be keyed by address")
+	}
+	clock.current = clock.current.Add(time.Second)
+	if _, ok = cache.Get(addr, request.TransactionID); ok {
+		t.Error("response should expire")
+	}
+	cache.Put(other, MustBuild(TransactionID, BindingSuccess))
+	if cache.Len() != 1 {
+		t.Errorf("expired response should be removed, len %d", cache.Len())
+	}
+	if NewResponseCache(0).ttl != DefaultResponseCacheTTL {
+		t.Error("default ttl should be used")
+	}
+}
@@ -9,7 +9,7 @@ package stun
 import (
 	"errors"
 
-	"github.com/pion/stun/v3/internal/hmac"
+	"github.com/pion/stun/v3/hmac"
 )
 
 // CheckSize returns ErrAttrSizeInvalid if got is not equal to expected.
@@ -4,11 +4,14 @@
 package stun
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
 	"net"
 	"runtime"
 	"strconv"
@@ -41,10 +44,15 @@ type DialConfig struct {
 	TLSConfig  tls.Config
 
 	Net transport.Net
+
+	// ClientOptions are passed to NewClient, e.g. WithStreamFraming for
+	// URIs with TCP transport.
+	ClientOptions []ClientOption
 }
 
 // DialURI connect to the STUN/TURN URI and then
 // initializes Client on that connection, returning error if any.
+// STUN URI is dialed over TCP if its Proto is ProtoTypeTCP.
 func DialURI(uri *URI, cfg *DialConfig) (*Client, error) { //nolint:cyclop
 	var conn Connection
 	var err error
@@ -61,7 +69,12 @@ func DialURI(uri *URI, cfg *DialConfig) (*Client, error) { //nolint:cyclop
 
 	switch {
 	case uri.Scheme == SchemeTypeSTUN:
-		if conn, err = nw.Dial("udp", addr); err != nil {
+		network := "udp"
+		if uri.Proto == ProtoTypeTCP {
+			network = "tcp"
+		}
+
+		if conn, err = nw.Dial(network, addr); err != nil {
 			return nil, fmt.Errorf("failed to listen: %w", err)
 		}
 
@@ -108,12 +121,24 @@ func DialURI(uri *URI, cfg *DialConfig) (*Client, error) { //nolint:cyclop
 		return nil, ErrUnsupportedURI
 	}
 
-	return NewClient(conn)
+	return NewClient(conn, cfg.ClientOptions...)
 }
 
 // ErrNoConnection means that ClientOptions.Connection is nil.
 var ErrNoConnection = errors.New("no connection provided")
 
+// ErrTTLNotSupported means that TTL of requests can't be set for the
+// connection or platform, see TTLOpt.
+var ErrTTLNotSupported = errors.New("setting TTL is not supported")
+
+// ErrSetDeadlineNotSupported means that ClientAgent does not implement
+// SetDeadline, see Client.SetDeadline.
+var ErrSetDeadlineNotSupported = errors.New("setting deadline is not supported by agent")
+
+// ErrKernelTimestampsNotSupported means that kernel receive timestamps are
+// not supported for the connection or platform, see WithKernelTimestamps.
+var ErrKernelTimestampsNotSupported = errors.New("kernel timestamps are not supported")
+
 // ClientOption sets some client option.
 type ClientOption func(c *Client)
 
@@ -126,6 +151,31 @@ func WithHandler(h Handler) ClientOption {
 	}
 }
 
+// WithIndicationHandler sets handler which is called for incoming
+// indications, e.g. keep-alives or Data indications from TURN server.
+// Indications are not passed to handler set via WithHandler then, so it
+// receives only unmatched responses and requests.
+func WithIndicationHandler(h Handler) ClientOption {
+	return func(c *Client) {
+		c.indicationHandler = h
+	}
+}
+
+// WithConnErrorHandler sets function that is called if reading from
+// connection fails with non-timeout error. Client keeps reading after
+// transient errors, e.g. ECONNREFUSED on UDP socket. If the transport died
+// (connection is closed or EOF is reached), the handler is called once and
+// the client stops receiving messages (see Connected), so the application
+// should create new connection or call SetConnection.
+//
+// The handler is not called for errors caused by the Close call. Passed
+// error implements net.Error, see IsNetworkError.
+func WithConnErrorHandler(h func(err error)) ClientOption {
+	return func(c *Client) {
+		c.onConnErr = h
+	}
+}
+
 // WithRTO sets client RTO as defined in STUN RFC.
 func WithRTO(rto time.Duration) ClientOption {
 	return func(c *Client) {
@@ -166,6 +216,78 @@ func WithCollector(coll Collector) ClientOption {
 	}
 }
 
+// WithPreciseTimeouts schedules a timer per transaction that fires exactly
+// at the transaction deadline, so timeouts are not delayed by up to one
+// tick of the Collector. Useful for ICE checks with tight pacing.
+//
+// The default ticker-based Collector is not started in this mode, but
+// the one set by WithCollector is.
+func WithPreciseTimeouts() ClientOption {
+	return func(c *Client) {
+		c.precise = true
+	}
+}
+
+// WithCloseTimeout sets the deadline for stopping the default collector
+// on Close, so a stuck Collect callback can't block Close forever.
+// If the deadline is exceeded, Close still closes connection and returns
+// ErrCollectorCloseTimeout, while agent is closed in background when
+// stuck Collect call returns. Zero d means no deadline.
+//
+// Defaults to 5 seconds.
+func WithCloseTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.closeTimeout = d
+	}
+}
+
+// WithRetransmitJitter randomizes each retransmission interval by
+// ±fraction of its value, e.g. 0.1 for ±10%, preventing synchronized
+// retransmission bursts of many transactions started at the same time.
+//
+// The fraction is clamped to [0, 1].
+func WithRetransmitJitter(fraction float64) ClientOption {
+	return func(c *Client) {
+		c.jitter = math.Max(0, math.Min(1, fraction))
+	}
+}
+
+// WithClonedEvents makes client pass each handler its own copy of
+// Event.Message, which, unlike the default one that is reused by the
+// read loop, stays valid after handler returns and can be retained
+// without CloneTo. Costs an allocation and copy per received message.
+func WithClonedEvents() ClientOption {
+	return func(c *Client) {
+		c.cloneEvents = true
+	}
+}
+
+// WithKernelTimestamps enables kernel receive timestamps of responses
+// (SO_TIMESTAMPNS), reported as Event.ReceivedAt and used for Event.RTT
+// instead of the time the response is handled, so read loop scheduling
+// delays are excluded. Send time is still taken from client clock, so
+// clock provided by WithClock should be based on wall clock.
+//
+// Only UDP connections on Linux are supported, otherwise NewClient and
+// SetConnection return ErrKernelTimestampsNotSupported.
+func WithKernelTimestamps() ClientOption {
+	return func(c *Client) {
+		c.kernelTimestamps = true
+	}
+}
+
+// WithStreamFraming makes client read messages from stream connection,
+// e.g. TCP, TLS or QUIC stream, by their length, RFC 8489 Section 6.2.2,
+// so responses coalesced into single read are not lost. ChannelData
+// messages, RFC 8656 Section 12.5, are framed with their padding and
+// ignored, so TURN connection can be shared. Other non-STUN data on the
+// stream breaks framing.
+func WithStreamFraming() ClientOption {
+	return func(c *Client) {
+		c.streamFraming = true
+	}
+}
+
 // WithNoConnClose prevents client from closing underlying connection when
 // the Close() method is called.
 func WithNoConnClose() ClientOption {
@@ -187,9 +309,10 @@ func WithNoRetransmit(c *Client) {
 }
 
 const (
-	defaultTimeoutRate = time.Millisecond * 5
-	defaultRTO         = time.Millisecond * 300
-	defaultMaxAttempts = 7
+	defaultTimeoutRate  = time.Millisecond * 5
+	defaultRTO          = time.Millisecond * 300
+	defaultMaxAttempts  = 7
+	defaultCloseTimeout = time.Second * 5
 )
 
 // NewClient initializes new Client from provided options,
@@ -205,14 +328,15 @@ const (
 // connection with your (de-)multiplexer and pass the wrapper as conn.
 func NewClient(conn Connection, options ...ClientOption) (*Client, error) {
 	client := &Client{
-		close:       make(chan struct{}),
-		c:           conn,
-		clock:       systemClock(),
-		rto:         int64(defaultRTO),
-		rtoRate:     defaultTimeoutRate,
-		t:           make(map[transactionID]*clientTransaction, 100),
-		maxAttempts: defaultMaxAttempts,
-		closeConn:   true,
+		close:        make(chan struct{}),
+		c:            conn,
+		clock:        systemClock(),
+		rto:          int64(defaultRTO),
+		rtoRate:      defaultTimeoutRate,
+		t:            make(map[transactionID]*clientTransaction, 100),
+		maxAttempts:  defaultMaxAttempts,
+		closeConn:    true,
+		closeTimeout: defaultCloseTimeout,
 	}
 	for _, o := range options {
 		o(client)
@@ -220,25 +344,28 @@ func NewClient(conn Connection, options ...ClientOption) (*Client, error) {
 	if client.c == nil {
 		return nil, ErrNoConnection
 	}
+	if client.kernelTimestamps {
+		if err := enableTimestamps(client.c); err != nil {
+			return nil, err
+		}
+	}
 	if client.a == nil {
 		client.a = NewAgent(nil)
 	}
 	if err := client.a.SetHandler(client.handleAgentCallback); err != nil {
 		return nil, err
 	}
+	if client.collector == nil && client.precise {
+		client.collector = noopCollector{}
+	}
 	if client.collector == nil {
-		client.collector = &tickerCollector{
-			close: make(chan struct{}),
-			clock: client.clock,
-		}
+		client.collector = newTickerCollector(client.clock, client.closeTimeout)
 	}
-	if err := client.collector.Start(client.rtoRate, func(t time.Time) {
-		closedOrPanic(client.a.Collect(t))
-	}); err != nil {
+	if err := client.collector.Start(client.rtoRate, client.collect); err != nil {
 		return nil, err
 	}
 	client.wg.Add(1)
-	go client.readUntilClosed()
+	go client.readUntilClosed(client.c)
 	runtime.SetFinalizer(client, clientFinalizer)
 
 	return client, nil
@@ -261,6 +388,9 @@ func clientFinalizer(c *Client) {
 }
 
 // Connection wraps Reader, Writer and Closer interfaces.
+//
+// Each Read should return single message, as for UDP. Use
+// WithStreamFraming for stream connections, e.g. TCP, TLS or QUIC stream.
 type Connection interface {
 	io.Reader
 	io.Writer
@@ -280,24 +410,89 @@ type ClientAgent interface {
 
 // Client simulates "connection" to STUN server.
 type Client struct {
-	rto         int64 // time.Duration
-	a           ClientAgent
-	c           Connection
-	close       chan struct{}
-	rtoRate     time.Duration
-	maxAttempts int32
-	closed      bool
-	closeConn   bool // should call c.Close() while closing
-	wg          sync.WaitGroup
-	clock       Clock
-	handler     Handler
-	collector   Collector
-	t           map[transactionID]*clientTransaction
-
-	// mux guards closed and t
+	rto          int64 // time.Duration
+	a            ClientAgent
+	c            Connection
+	close        chan struct{}
+	rtoRate      time.Duration
+	closeTimeout time.Duration // deadline for closing default collector
+	maxAttempts  int32
+	closed       bool
+	connFailed   bool // reading from c failed, see Connected
+	closeConn    bool // should call c.Close() while closing
+	precise      bool // use per-transaction timers instead of collector
+	cloneEvents  bool // see WithClonedEvents
+	jitter       float64
+	wg           sync.WaitGroup
+	clock        Clock
+	handler      Handler
+	onConnErr    func(err error) // see WithConnErrorHandler
+	collector    Collector
+	t            map[transactionID]*clientTransaction
+	stats        clientStats
+
+	indicationHandler Handler // see WithIndicationHandler
+	kernelTimestamps  bool    // see WithKernelTimestamps
+	streamFraming     bool    // see WithStreamFraming
+
+	// writeMux is locked for writing while connection TTL is changed,
+	// see TTLOpt, and for reading during other writes.
+	writeMux sync.RWMutex
+
+	// collectMux is held during agent Collect calls by collector, so agent
+	// is not closed during stuck call, see Close.
+	collectMux     sync.Mutex
+	collectStopped bool // guarded by collectMux
+
+	// mux guards c, closed, connFailed and t
 	mux sync.RWMutex
 }
 
+// clientStats holds Client counters, see ClientStats.
+type clientStats struct {
+	started         atomic.Uint64
+	succeeded       atomic.Uint64
+	timedOut        atomic.Uint64
+	retransmissions atomic.Uint64
+	bytesSent       atomic.Uint64
+	bytesReceived   atomic.Uint64
+}
+
+// ClientStats is a snapshot of Client counters.
+type ClientStats struct {
+	TransactionsStarted   uint64 // transactions started via Start or Do
+	TransactionsSucceeded uint64 // transactions completed with response
+	TransactionsTimedOut  uint64 // transactions completed with ErrTransactionTimeOut
+	Retransmissions       uint64 // requests written again after timeout
+	BytesSent             uint64 // bytes written to connection
+	BytesReceived         uint64 // bytes read from connection
+	DuplicateResponses    uint64 // see AgentStats, zero if agent is not *Agent
+	LateResponses         uint64 // see AgentStats, zero if agent is not *Agent
+	RTO                   time.Duration
+}
+
+// Stats returns snapshot of client counters and current RTO.
+//
+// Counters are updated independently, so the snapshot is not atomic.
+func (c *Client) Stats() ClientStats {
+	stats := ClientStats{
+		TransactionsStarted:   c.stats.started.Load(),
+		TransactionsSucceeded: c.stats.succeeded.Load(),
+		TransactionsTimedOut:  c.stats.timedOut.Load(),
+		Retransmissions:       c.stats.retransmissions.Load(),
+		BytesSent:             c.stats.bytesSent.Load(),
+		BytesReceived:         c.stats.bytesReceived.Load(),
+		RTO:                   c.RTO(),
+	}
+	if a, ok := c.a.(*Agent); ok {
+		agentStats := a.Stats()
+		stats.DuplicateResponses = agentStats.DuplicateResponses
+		stats.LateResponses = agentStats.LateResponses
+	}
+
+	return stats
+}
+
 // clientTransaction represents transaction in progress.
 // If transaction is succeed or failed, f will be called
 // provided by event.
@@ -308,8 +503,43 @@ type clientTransaction struct {
 	calls   int32
 	h       Handler
 	start   time.Time
+	sent    time.Time // time of the last (re)transmission
 	rto     time.Duration
 	raw     []byte
+	timer   *time.Timer // non-nil only if precise
+	jitter  float64     // fraction of interval, see WithRetransmitJitter
+	// noRetransmit disables retransmissions, see NoRetransmitOpt.
+	noRetransmit bool
+	ttl          int       // IP TTL of requests if not zero, see TTLOpt
+	received     time.Time // kernel receive timestamp of response, if any
+}
+
+// TransactionOption sets option of single transaction started via
+// Start, Do or DoContext.
+type TransactionOption func(t *clientTransaction)
+
+// NoRetransmitOpt disables retransmissions for single transaction, e.g.
+// for requests over reliable transport or one-shot diagnostics. The
+// transaction times out after RTO multiplied by maximum number of
+// attempts, the time budget that retransmissions would otherwise use.
+//
+//nolint:gochecknoglobals
+var NoRetransmitOpt TransactionOption = func(t *clientTransaction) {
+	t.noRetransmit = true
+}
+
+// TTLOpt sets IP TTL (hop limit for IPv6) of requests of single
+// transaction, including retransmissions, e.g. for NAT hop or middlebox
+// discovery with expiring probes. Other writes are blocked while TTL is
+// changed. Requires UDP connection on Linux, macOS or BSD, otherwise
+// starting transaction fails with ErrTTLNotSupported.
+//
+// ICMP Time Exceeded errors are not reported, so expired probe results in
+// ErrTransactionTimeOut.
+func TTLOpt(ttl int) TransactionOption {
+	return func(t *clientTransaction) {
+		t.ttl = ttl
+	}
 }
 
 func (t *clientTransaction) handle(e Event) {
@@ -331,6 +561,7 @@ func acquireClientTransaction() *clientTransaction {
 }
 
 func putClientTransaction(t *clientTransaction) {
+	t.stopTimer()
 	t.raw = t.raw[:0]
 	t.start = time.Time{}
 	t.attempt = 0
@@ -339,7 +570,40 @@ func putClientTransaction(t *clientTransaction) {
 }
 
 func (t *clientTransaction) nextTimeout(now time.Time) time.Time {
-	return now.Add(time.Duration(t.attempt+1) * t.rto)
+	interval := time.Duration(t.attempt+1) * t.rto
+	if t.jitter > 0 {
+		// Randomizing interval by ±jitter.
+		interval = time.Duration(float64(interval) * (1 + t.jitter*(2*rand.Float64()-1))) //nolint:gosec
+	}
+
+	return now.Add(interval)
+}
+
+func (t *clientTransaction) stopTimer() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// scheduleTimeout sets timer of t to collect timed out transactions
+// right after the deadline if precise timeouts are enabled.
+//
+// Should be called before registering t, because t could be completed
+// concurrently after that.
+func (c *Client) scheduleTimeout(t *clientTransaction, deadline time.Time) {
+	if !c.precise {
+		return
+	}
+	t.stopTimer()
+	t.timer = time.AfterFunc(deadline.Sub(c.clock.Now()), func() {
+		now := c.clock.Now()
+		if !now.After(deadline) {
+			// Agent collects only transactions with deadline before now.
+			now = deadline.Add(time.Nanosecond)
+		}
+		closedOrPanic(c.a.Collect(now))
+	})
 }
 
 // start registers transaction.
@@ -378,6 +642,68 @@ func (c *Client) SetRTO(rto time.Duration) {
 	atomic.StoreInt64(&c.rto, int64(rto))
 }
 
+// RTO returns current RTO value that is used for new transactions.
+//
+// Retransmission of transaction with attempt N (starting from zero)
+// times out after (N+1)*RTO.
+func (c *Client) RTO() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.rto))
+}
+
+// TransactionAttempts returns number of times the request of transaction
+// with provided id was written to connection, including retransmissions.
+// The id is the transaction id of the message passed to Start or Do.
+//
+// Returns false if the transaction is not in progress.
+func (c *Client) TransactionAttempts(id [TransactionIDSize]byte) (int, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	t, found := c.t[id]
+	if !found {
+		return 0, false
+	}
+
+	return int(t.attempt) + 1, true
+}
+
+// deadlineSetter is implemented by ClientAgent that supports changing
+// deadline of pending transaction, e.g. Agent.
+type deadlineSetter interface {
+	SetDeadline(id [TransactionIDSize]byte, deadline time.Time) error
+}
+
+// SetDeadline sets deadline of current attempt of transaction with
+// provided id, e.g. to extend it for slow server without stopping and
+// restarting transaction. On deadline the request is retransmitted or
+// transaction times out as usual. Timer is rescheduled if precise
+// timeouts are enabled, see WithPreciseTimeouts.
+//
+// Could return ErrClientClosed, ErrTransactionNotExists and
+// ErrSetDeadlineNotSupported if agent does not implement SetDeadline.
+func (c *Client) SetDeadline(id [TransactionIDSize]byte, deadline time.Time) error {
+	setter, ok := c.a.(deadlineSetter)
+	if !ok {
+		return ErrSetDeadlineNotSupported
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return ErrClientClosed
+	}
+	t, found := c.t[id]
+	if !found {
+		return ErrTransactionNotExists
+	}
+	if err := setter.SetDeadline(id, deadline); err != nil {
+		return err
+	}
+	// Transaction is modified only by its owner when it is not registered,
+	// so holding c.mux is enough.
+	c.scheduleTimeout(t, deadline)
+
+	return nil
+}
+
 // StopErr occurs when Client fails to stop transaction while
 // processing error.
 //
@@ -391,6 +717,18 @@ func (e StopErr) Error() string {
 	return fmt.Sprintf("error while stopping due to %s: %s", sprintErr(e.Cause), sprintErr(e.Err))
 }
 
+// Unwrap returns both Err and Cause, so errors.Is and errors.As
+// (and IsTimeout or IsNetworkError) can inspect them.
+func (e StopErr) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
+// Timeout reports whether Err or Cause is a timeout. Allows os.IsTimeout,
+// that does not unwrap errors, to classify StopErr.
+func (e StopErr) Timeout() bool {
+	return IsTimeout(e.Err) || IsTimeout(e.Cause)
+}
+
 // CloseErr indicates client close failure.
 //
 //nolint:errname
@@ -411,23 +749,317 @@ func (c CloseErr) Error() string {
 	return fmt.Sprintf("failed to close: %s (connection), %s (agent)", sprintErr(c.ConnectionErr), sprintErr(c.AgentErr))
 }
 
-func (c *Client) readUntilClosed() {
+// Unwrap returns both ConnectionErr and AgentErr.
+func (c CloseErr) Unwrap() []error {
+	return []error{c.ConnectionErr, c.AgentErr}
+}
+
+// Timeout reports whether ConnectionErr or AgentErr is a timeout.
+func (c CloseErr) Timeout() bool {
+	return IsTimeout(c.ConnectionErr) || IsTimeout(c.AgentErr)
+}
+
+// readUntilClosed reads and processes messages from conn until client
+// is closed or conn is replaced via SetConnection.
+func (c *Client) readUntilClosed(conn Connection) { //nolint:cyclop
 	defer c.wg.Done()
 	m := new(Message)
 	m.Raw = make([]byte, 1024)
+	var (
+		reader   io.Reader = conn
+		msgConn  msgReader
+		oob      []byte
+		received time.Time
+	)
+	if c.streamFraming {
+		reader = &streamReader{r: conn}
+	}
+	if c.kernelTimestamps {
+		msgConn, _ = conn.(msgReader)
+		oob = make([]byte, timestampOOBSize)
+	}
 	for {
 		select {
 		case <-c.close:
 			return
 		default:
 		}
-		_, err := m.ReadFrom(c.c)
-		if err == nil {
-			if pErr := c.a.Process(m); errors.Is(pErr, ErrAgentClosed) {
+		var (
+			n   int
+			err error
+		)
+		if msgConn != nil {
+			var oobn int
+			n, oobn, _, _, err = msgConn.ReadMsgUDP(m.Raw[:cap(m.Raw)], oob)
+			received = parseTimestamp(oob[:oobn])
+		} else {
+			n, err = reader.Read(m.Raw[:cap(m.Raw)])
+		}
+		c.stats.bytesReceived.Add(uint64(n)) //nolint:gosec // G115
+		if err != nil {
+			if IsTimeout(err) && conn == c.conn() {
+				continue
+			}
+			// Other errors can be transient, e.g. ECONNREFUSED caused by
+			// ICMP port unreachable on connected UDP socket.
+			fatal := isFatalReadErr(err) || conn != c.conn()
+			c.handleConnError(conn, err, fatal)
+			if fatal {
 				return
 			}
+
+			continue
+		}
+		m.Raw = m.Raw[:n]
+		if decodeErr := m.Decode(); decodeErr != nil {
+			continue
+		}
+		if !received.IsZero() {
+			c.setReceived(m.TransactionID, received)
+		}
+		if pErr := c.a.Process(m); errors.Is(pErr, ErrAgentClosed) {
+			return
+		}
+	}
+}
+
+// msgReader is implemented by *net.UDPConn, used to read kernel
+// timestamps, see WithKernelTimestamps.
+type msgReader interface {
+	ReadMsgUDP(b, oob []byte) (n, oobn, flags int, addr *net.UDPAddr, err error)
+}
+
+// streamReader reads single STUN or ChannelData message from stream per
+// Read, using message length from header, see WithStreamFraming. Data
+// after the message is kept for the next Read.
+type streamReader struct {
+	r          io.Reader
+	buf        []byte
+	start, end int   // buffered data is buf[start:end]
+	err        error // read error to return after buffered messages
+}
+
+const (
+	streamReaderBufSize   = 2048
+	channelDataHeaderSize = 4
+)
+
+// streamFrameSize returns size of message that b starts with, or zero if
+// more bytes are needed to determine it.
+func streamFrameSize(b []byte) int {
+	if len(b) > 0 && b[0]&0xC0 == 0x40 {
+		// ChannelData with channel number from 0x4000 to 0x7FFF, padded
+		// to 4 bytes over stream, RFC 8656 Section 12.5.
+		if len(b) < channelDataHeaderSize {
+			return 0
 		}
+
+		return channelDataHeaderSize + nearestPaddedValueLength(int(bin.Uint16(b[2:4])))
 	}
+	if len(b) < messageHeaderSize {
+		return 0
+	}
+
+	return messageHeaderSize + int(bin.Uint16(b[2:4]))
+}
+
+// Read reads next message to b, truncating it if b is too small.
+func (s *streamReader) Read(b []byte) (int, error) {
+	for {
+		size := streamFrameSize(s.buf[s.start:s.end])
+		if size > 0 && s.end-s.start >= size {
+			n := copy(b, s.buf[s.start:s.start+size])
+			s.start += size
+
+			return n, nil
+		}
+		if s.err != nil {
+			err := s.err
+			s.err = nil
+
+			return 0, err
+		}
+		// Moving partial message to the start of buffer.
+		s.end = copy(s.buf, s.buf[s.start:s.end])
+		s.start = 0
+		if size < streamReaderBufSize {
+			size = streamReaderBufSize
+		}
+		if len(s.buf) < size {
+			buf := make([]byte, size)
+			copy(buf, s.buf[:s.end])
+			s.buf = buf
+		}
+		n, err := s.r.Read(s.buf[s.end:])
+		s.end += n
+		if err != nil {
+			if n == 0 {
+				return 0, err
+			}
+			s.err = err
+		}
+	}
+}
+
+// setReceived sets kernel receive timestamp of response to transaction
+// with provided id, if it is in progress.
+func (c *Client) setReceived(id transactionID, received time.Time) {
+	c.mux.Lock()
+	if t, ok := c.t[id]; ok {
+		t.received = received
+	}
+	c.mux.Unlock()
+}
+
+// isFatalReadErr reports whether reading from connection can't continue
+// after err.
+func isFatalReadErr(err error) bool {
+	return errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// handleConnError passes read error to onConnErr if client is not closed
+// and conn is not replaced, marking conn as failed if error is fatal.
+func (c *Client) handleConnError(conn Connection, err error, fatal bool) {
+	c.mux.Lock()
+	ignore := c.closed || c.c != conn
+	if !ignore && fatal {
+		c.connFailed = true
+	}
+	c.mux.Unlock()
+	if ignore || c.onConnErr == nil {
+		return
+	}
+	if !IsNetworkError(err) {
+		// E.g. io.EOF from stream connection.
+		err = &netError{msg: "connection failed: " + err.Error(), err: err}
+	}
+	c.onConnErr(err)
+}
+
+// write writes b to current connection, setting IP TTL to ttl for this
+// write if ttl is not zero.
+func (c *Client) write(b []byte, ttl int) (int, error) {
+	conn := c.conn()
+	if ttl == 0 {
+		c.writeMux.RLock()
+		defer c.writeMux.RUnlock()
+
+		return conn.Write(b)
+	}
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+	restore, err := setTTL(conn, ttl)
+	if err != nil {
+		return 0, err
+	}
+	n, err := conn.Write(b)
+	if restoreErr := restore(); err == nil {
+		err = restoreErr
+	}
+
+	return n, err
+}
+
+// conn returns current connection.
+func (c *Client) conn() Connection {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.c
+}
+
+// LocalAddr returns local network address of the current connection or
+// nil if the connection does not implement LocalAddr.
+func (c *Client) LocalAddr() net.Addr {
+	return localAddr(c.conn())
+}
+
+func localAddr(conn Connection) net.Addr {
+	if conn, ok := conn.(interface{ LocalAddr() net.Addr }); ok {
+		return conn.LocalAddr()
+	}
+
+	return nil
+}
+
+// RemoteAddr returns remote network address of the current connection or
+// nil if the connection does not implement RemoteAddr.
+func (c *Client) RemoteAddr() net.Addr {
+	if conn, ok := c.conn().(interface{ RemoteAddr() net.Addr }); ok {
+		return conn.RemoteAddr()
+	}
+
+	return nil
+}
+
+// Connected returns true if client is not closed and reading from the
+// current connection has not failed.
+func (c *Client) Connected() bool {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return !c.closed && !c.connFailed
+}
+
+// SetConnection replaces the underlying connection with conn, preserving
+// agent state and pending transactions, e.g. on network change. Further
+// writes (including retransmissions) go to conn, and responses are read
+// from conn.
+//
+// The previous connection is closed, so responses that are in flight on it
+// are lost and corresponding transactions are completed by retransmissions
+// over conn. With WithNoConnClose, the previous connection is left open and
+// responses are still read from it until any read error occurs, including
+// deadline expiration.
+func (c *Client) SetConnection(conn Connection) error {
+	if err := c.checkInit(); err != nil {
+		return err
+	}
+	if conn == nil {
+		return ErrNoConnection
+	}
+	if c.kernelTimestamps {
+		if err := enableTimestamps(conn); err != nil {
+			return err
+		}
+	}
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+
+		return ErrClientClosed
+	}
+	prev := c.c
+	c.c = conn
+	c.connFailed = false
+	c.wg.Add(1)
+	c.mux.Unlock()
+	go c.readUntilClosed(conn)
+	if c.closeConn {
+		return prev.Close()
+	}
+
+	return nil
+}
+
+// collect is called by collector to collect timed out transactions.
+func (c *Client) collect(now time.Time) {
+	c.collectMux.Lock()
+	defer c.collectMux.Unlock()
+	if c.collectStopped {
+		return
+	}
+	closedOrPanic(c.a.Collect(now))
+}
+
+// closeAgent closes agent once collect call in progress, if any, returns,
+// preventing further calls.
+func (c *Client) closeAgent() error {
+	c.collectMux.Lock()
+	c.collectStopped = true
+	c.collectMux.Unlock()
+
+	return c.a.Close()
 }
 
 func closedOrPanic(err error) {
@@ -437,10 +1069,37 @@ func closedOrPanic(err error) {
 	panic(err) //nolint
 }
 
+// noopCollector is Collector that does nothing, used when
+// timeouts are handled by per-transaction timers.
+type noopCollector struct{}
+
+func (noopCollector) Start(time.Duration, func(now time.Time)) error { return nil }
+
+func (noopCollector) Close() error { return nil }
+
+// ErrCollectorCloseTimeout means that collector failed to stop before
+// the close deadline, e.g. because of the stuck Collect callback.
+var ErrCollectorCloseTimeout = errors.New("collector close timed out")
+
+// tickerCollector calls function on each tick until the context is done.
 type tickerCollector struct {
-	close chan struct{}
-	wg    sync.WaitGroup
-	clock Clock
+	ctx          context.Context //nolint:containedctx
+	cancel       context.CancelFunc
+	done         chan struct{} // closed when ticker goroutine exits
+	clock        Clock
+	closeTimeout time.Duration // zero means no deadline
+}
+
+func newTickerCollector(clock Clock, closeTimeout time.Duration) *tickerCollector {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &tickerCollector{
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+		clock:        clock,
+		closeTimeout: closeTimeout,
+	}
 }
 
 // Collector calls function f with constant rate.
@@ -453,14 +1112,12 @@ type Collector interface {
 
 func (a *tickerCollector) Start(rate time.Duration, f func(now time.Time)) error {
 	t := time.NewTicker(rate)
-	a.wg.Add(1)
 	go func() {
-		defer a.wg.Done()
+		defer close(a.done)
+		defer t.Stop()
 		for {
 			select {
-			case <-a.close:
-				t.Stop()
-
+			case <-a.ctx.Done():
 				return
 			case <-t.C:
 				f(a.clock.Now())
@@ -471,15 +1128,30 @@ func (a *tickerCollector) Start(rate time.Duration, f func(now time.Time)) error
 	return nil
 }
 
+// Close cancels the collector context and waits until the ticker goroutine
+// exits, returning ErrCollectorCloseTimeout if it takes longer than
+// closeTimeout.
 func (a *tickerCollector) Close() error {
-	close(a.close)
-	a.wg.Wait()
+	a.cancel()
+	if a.closeTimeout <= 0 {
+		<-a.done
 
-	return nil
+		return nil
+	}
+	timer := time.NewTimer(a.closeTimeout)
+	defer timer.Stop()
+	select {
+	case <-a.done:
+		return nil
+	case <-timer.C:
+		return ErrCollectorCloseTimeout
+	}
 }
 
 // ErrClientClosed indicates that client is closed.
-var ErrClientClosed = errors.New("client is closed")
+//
+// Implements net.Error with Timeout() and Temporary() returning false.
+var ErrClientClosed error = &netError{msg: "client is closed"} //nolint:gochecknoglobals
 
 // Close stops internal connection and agent, returning CloseErr on error.
 func (c *Client) Close() error {
@@ -494,18 +1166,27 @@ func (c *Client) Close() error {
 	}
 	c.closed = true
 	c.mux.Unlock()
-	if closeErr := c.collector.Close(); closeErr != nil {
-		return closeErr
+	// Proceeding on close timeout, so stuck collector can't prevent
+	// closing the connection.
+	collectorErr := c.collector.Close()
+	var agentErr, connErr error
+	switch {
+	case collectorErr == nil:
+		agentErr = c.closeAgent()
+	case errors.Is(collectorErr, ErrCollectorCloseTimeout):
+		// Agent is closed when stuck Collect call returns, as closing it
+		// during the call is not safe for all ClientAgent implementations.
+		go func() { _ = c.closeAgent() }()
+	default:
+		return collectorErr
 	}
-	var connErr error
-	agentErr := c.a.Close()
 	if c.closeConn {
-		connErr = c.c.Close()
+		connErr = c.conn().Close()
 	}
 	close(c.close)
 	c.wg.Wait()
 	if agentErr == nil && connErr == nil {
-		return nil
+		return collectorErr
 	}
 
 	return CloseErr{
@@ -520,52 +1201,65 @@ func (c *Client) Indicate(m *Message) error {
 	return c.Start(m, nil)
 }
 
-// callbackWaitHandler blocks on wait() call until callback is called.
+// callbackWaitHandler calls callback on event and unblocks wait() call.
 type callbackWaitHandler struct {
-	handler   Handler
-	callback  func(event Event)
-	cond      *sync.Cond
-	processed bool
+	handler  Handler
+	callback func(event Event)
+	done     chan struct{}
+	state    int32 // see callbackWaiting, callbackHandled, callbackCanceled
+}
+
+// Possible states of callbackWaitHandler.
+const (
+	callbackWaiting int32 = iota
+	callbackHandled
+	callbackCanceled
+)
+
+func newCallbackWaitHandler() *callbackWaitHandler {
+	h := &callbackWaitHandler{
+		done: make(chan struct{}, 1),
+	}
+	h.handler = h.HandleEvent
+
+	return h
 }
 
+// HandleEvent calls callback if waiting was not canceled.
 func (s *callbackWaitHandler) HandleEvent(e Event) {
-	s.cond.L.Lock()
-	if s.callback == nil {
-		panic("s.callback is nil") //nolint
+	if !atomic.CompareAndSwapInt32(&s.state, callbackWaiting, callbackHandled) {
+		return
 	}
 	s.callback(e)
-	s.processed = true
-	s.cond.Broadcast()
-	s.cond.L.Unlock()
+	s.done <- struct{}{}
 }
 
-func (s *callbackWaitHandler) wait() {
-	s.cond.L.Lock()
-	for !s.processed {
-		s.cond.Wait()
+// wait blocks until callback returns or ctx is done. Returns ctx.Err()
+// if callback will not be called because waiting was canceled, in this
+// case s must not be reused.
+func (s *callbackWaitHandler) wait(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
 	}
-	s.processed = false
-	s.callback = nil
-	s.cond.L.Unlock()
+	if atomic.CompareAndSwapInt32(&s.state, callbackWaiting, callbackCanceled) {
+		return ctx.Err()
+	}
+	// Callback is already called, waiting until it returns.
+	<-s.done
+
+	return nil
 }
 
 func (s *callbackWaitHandler) setCallback(f func(event Event)) {
-	if f == nil {
-		panic("f is nil") //nolint
-	}
-	s.cond.L.Lock()
 	s.callback = f
-	if s.handler == nil {
-		s.handler = s.HandleEvent
-	}
-	s.cond.L.Unlock()
+	atomic.StoreInt32(&s.state, callbackWaiting)
 }
 
 var callbackWaitHandlerPool = sync.Pool{ //nolint:gochecknoglobals
 	New: func() interface{} {
-		return &callbackWaitHandler{
-			cond: sync.NewCond(new(sync.Mutex)),
-		}
+		return newCallbackWaitHandler()
 	},
 }
 
@@ -573,7 +1267,7 @@ var callbackWaitHandlerPool = sync.Pool{ //nolint:gochecknoglobals
 var ErrClientNotInitialized = errors.New("client not initialized")
 
 func (c *Client) checkInit() error {
-	if c == nil || c.c == nil || c.a == nil || c.close == nil {
+	if c == nil || c.conn() == nil || c.a == nil || c.close == nil {
 		return ErrClientNotInitialized
 	}
 
@@ -585,7 +1279,13 @@ func (c *Client) checkInit() error {
 //
 // Do has cpu overhead due to blocking, see BenchmarkClient_Do.
 // Use Start method for less overhead.
-func (c *Client) Do(m *Message, f func(Event)) error {
+func (c *Client) Do(m *Message, f func(Event), opts ...TransactionOption) error {
+	return c.DoContext(context.Background(), m, f, opts...)
+}
+
+// DoContext is like Do, but stops waiting when ctx is done, returning
+// ctx.Err(). In that case transaction is stopped and f is not called.
+func (c *Client) DoContext(ctx context.Context, m *Message, f func(Event), opts ...TransactionOption) error {
 	if err := c.checkInit(); err != nil {
 		return err
 	}
@@ -594,17 +1294,85 @@ func (c *Client) Do(m *Message, f func(Event)) error {
 	}
 	h := callbackWaitHandlerPool.Get().(*callbackWaitHandler) //nolint:forcetypeassert
 	h.setCallback(f)
-	defer func() {
+	if err := c.Start(m, h.handler, opts...); err != nil {
 		callbackWaitHandlerPool.Put(h)
-	}()
-	if err := c.Start(m, h.handler); err != nil {
+
 		return err
 	}
-	h.wait()
+	if err := h.wait(ctx); err != nil {
+		// Not returning h to pool, as transaction could still be
+		// handled by it.
+		c.delete(m.TransactionID)
+		_ = c.a.Stop(m.TransactionID)
+
+		return err
+	}
+	callbackWaitHandlerPool.Put(h)
 
 	return nil
 }
 
+var messagePool = &sync.Pool{ //nolint:gochecknoglobals
+	New: func() interface{} {
+		return New()
+	},
+}
+
+// DoSetters builds message from setters using pooled Message and calls Do,
+// so callers don't need to manage message lifetime for simple requests:
+//
+//	c.DoSetters(f, stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+//
+// Setters should include TransactionID, otherwise zero transaction id is used.
+func (c *Client) DoSetters(f func(Event), setters ...Setter) error {
+	m := messagePool.Get().(*Message) //nolint:forcetypeassert
+	defer messagePool.Put(m)
+	if err := m.Build(setters...); err != nil {
+		return err
+	}
+
+	return c.Do(m, f)
+}
+
+// ErrErrorResponse means that server responded with error response.
+var ErrErrorResponse = errors.New("error response")
+
+// Ping performs binding request and returns its round-trip time (see
+// Event.RTT), e.g. for liveness probes of ICE servers. Waiting is stopped
+// with ctx.Err() when ctx is done.
+//
+// Error response is returned along with round-trip time as error wrapping
+// ErrErrorResponse.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	m := messagePool.Get().(*Message) //nolint:forcetypeassert
+	defer messagePool.Put(m)
+	if err := m.Build(TransactionID, BindingRequest); err != nil {
+		return 0, err
+	}
+	var (
+		rtt    time.Duration
+		resErr error
+	)
+	if err := c.DoContext(ctx, m, func(e Event) {
+		if e.Error != nil {
+			resErr = e.Error
+
+			return
+		}
+		rtt = e.RTT
+		switch {
+		case e.ErrorCode != nil:
+			resErr = fmt.Errorf("%w: %s", ErrErrorResponse, e.ErrorCode)
+		case e.Message.Type.Class == ClassErrorResponse:
+			resErr = ErrErrorResponse
+		}
+	}); err != nil {
+		return 0, err
+	}
+
+	return rtt, resErr
+}
+
 func (c *Client) delete(id transactionID) {
 	c.mux.Lock()
 	if c.t != nil {
@@ -623,6 +1391,20 @@ var bufferPool = &sync.Pool{ //nolint:gochecknoglobals
 	},
 }
 
+// parseErrorResponse sets error response attributes of e from e.Message.
+func (e *Event) parseErrorResponse() {
+	code := new(ErrorCodeAttribute)
+	if code.GetFrom(e.Message) == nil {
+		e.ErrorCode = code
+	}
+	_ = e.Realm.GetFrom(e.Message)
+	_ = e.Nonce.GetFrom(e.Message)
+	server := new(AlternateServer)
+	if server.GetFrom(e.Message) == nil {
+		e.AlternateServer = server
+	}
+}
+
 func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 	c.mux.Lock()
 	if c.closed {
@@ -635,15 +1417,42 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 		delete(c.t, transaction.id)
 	}
 	c.mux.Unlock()
+	if c.cloneEvents && event.Message != nil {
+		m := new(Message)
+		if err := event.Message.CloneTo(m); err == nil {
+			event.Message = m
+		}
+	}
+	if event.Message != nil && event.Message.Type.Class == ClassErrorResponse {
+		event.parseErrorResponse()
+	}
 	if !found {
+		if c.indicationHandler != nil && event.Message != nil && event.Message.Type.Class == ClassIndication {
+			c.indicationHandler(event)
+
+			return
+		}
 		if c.handler != nil && !errors.Is(event.Error, ErrTransactionStopped) {
 			c.handler(event)
 		}
 		// Ignoring.
 		return
 	}
-	if atomic.LoadInt32(&c.maxAttempts) <= transaction.attempt || event.Error == nil {
+	if atomic.LoadInt32(&c.maxAttempts) <= transaction.attempt || event.Error == nil || transaction.noRetransmit {
 		// Transaction completed.
+		switch {
+		case event.Error == nil:
+			c.stats.succeeded.Add(1)
+			now := c.clock.Now()
+			if !transaction.received.IsZero() {
+				now = transaction.received
+				event.ReceivedAt = now
+			}
+			event.RTT = now.Sub(transaction.start)
+			event.AttemptRTT = now.Sub(transaction.sent)
+		case errors.Is(event.Error, ErrTransactionTimeOut):
+			c.stats.timedOut.Add(1)
+		}
 		transaction.handle(event)
 		putClientTransaction(transaction)
 
@@ -658,7 +1467,10 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 		now     = c.clock.Now()
 		timeOut = transaction.nextTimeout(now)
 		id      = transaction.id
+		ttl     = transaction.ttl
 	)
+	transaction.sent = now
+	c.scheduleTimeout(transaction, timeOut)
 	// Starting client transaction.
 	if startErr := c.start(transaction); startErr != nil {
 		c.delete(id)
@@ -678,7 +1490,8 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 		return
 	}
 	// Writing message to connection again.
-	_, writeErr := c.c.Write(buff.buf)
+	n, writeErr := c.write(buff.buf, ttl)
+	c.stats.bytesSent.Add(uint64(n)) //nolint:gosec // G115
 	if writeErr != nil {
 		c.delete(id)
 		event.Error = writeErr
@@ -697,11 +1510,12 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 
 		return
 	}
+	c.stats.retransmissions.Add(1)
 }
 
 // Start starts transaction (if h set) and writes message to server, handler
-// is called asynchronously.
-func (c *Client) Start(msg *Message, handler Handler) error {
+// is called asynchronously. Options are ignored if h is not set.
+func (c *Client) Start(msg *Message, handler Handler, opts ...TransactionOption) error {
 	if err := c.checkInit(); err != nil {
 		return err
 	}
@@ -711,25 +1525,44 @@ func (c *Client) Start(msg *Message, handler Handler) error {
 	if closed {
 		return ErrClientClosed
 	}
+	ttl := 0
 	if handler != nil {
 		// Starting transaction only if h is set. Useful for indications.
 		t := acquireClientTransaction()
 		t.id = msg.TransactionID
 		t.start = c.clock.Now()
+		t.sent = t.start
 		t.h = handler
 		t.rto = time.Duration(atomic.LoadInt64(&c.rto))
+		t.jitter = c.jitter
 		t.attempt = 0
 		t.raw = append(t.raw[:0], msg.Raw...)
 		t.calls = 0
+		t.noRetransmit = false
+		t.ttl = 0
+		t.received = time.Time{}
+		for _, o := range opts {
+			o(t)
+		}
+		ttl = t.ttl
 		d := t.nextTimeout(t.start)
+		if t.noRetransmit {
+			attempts := time.Duration(atomic.LoadInt32(&c.maxAttempts) + 1)
+			d = t.start.Add(attempts * t.rto)
+		}
+		c.scheduleTimeout(t, d)
 		if err := c.start(t); err != nil {
+			t.stopTimer()
+
 			return err
 		}
 		if err := c.a.Start(msg.TransactionID, d); err != nil {
 			return err
 		}
+		c.stats.started.Add(1)
 	}
-	_, err := msg.WriteTo(c.c)
+	n, err := c.write(msg.Raw, ttl)
+	c.stats.bytesSent.Add(uint64(n)) //nolint:gosec // G115
 	if err != nil && handler != nil {
 		c.delete(msg.TransactionID)
 		// Stopping transaction instead of waiting until deadline.
@@ -9,6 +9,7 @@ package stun
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -16,7 +17,10 @@ import (
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -435,26 +439,17 @@ func TestClientConnErrStopErr(t *testing.T) {
 	}
 }
 
-func TestCallbackWaitHandler_setCallback(t *testing.T) {
-	c := callbackWaitHandler{}
-	defer func() {
-		if err := recover(); err == nil {
-			t.Error("should panic")
-		}
-	}()
-	c.setCallback(nil)
-}
-
-func TestCallbackWaitHandler_HandleEvent(t *testing.T) {
-	c := &callbackWaitHandler{
-		cond: sync.NewCond(new(sync.Mutex)),
+func TestCallbackWaitHandler_Cancel(t *testing.T) {
+	h := newCallbackWaitHandler()
+	h.setCallback(func(Event) {
+		t.Error("should not be called")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
 	}
-	defer func() {
-		if err := recover(); err == nil {
-			t.Error("should panic")
-		}
-	}()
-	c.HandleEvent(Event{})
+	h.HandleEvent(Event{})
 }
 
 func TestNewClientNoConnection(t *testing.T) {
@@ -493,6 +488,32 @@ func TestDialURI(t *testing.T) {
 			t.Error(err)
 		}
 	}()
+	t.Run("TCP", func(t *testing.T) {
+		l, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close() //nolint:errcheck
+		addr, _ := l.Addr().(*net.TCPAddr)
+		c, err := DialURI(&URI{
+			Scheme: SchemeTypeSTUN,
+			Host:   "127.0.0.1",
+			Port:   addr.Port,
+			Proto:  ProtoTypeTCP,
+		}, &DialConfig{ClientOptions: []ClientOption{WithStreamFraming()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if network := c.RemoteAddr().Network(); network != "tcp" {
+			t.Errorf("unexpected network %s", network)
+		}
+		if !c.streamFraming {
+			t.Error("client options are not applied")
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	})
 }
 
 func TestDialError(t *testing.T) {
@@ -594,23 +615,41 @@ func TestClientGC(t *testing.T) {
 	agent := &gcWaitAgent{
 		gc: make(chan struct{}),
 	}
+	const closeTimeout = time.Millisecond * 50
 	c, err := NewClient(conn,
 		WithAgent(agent),
 		WithTimeoutRate(time.Millisecond),
+		WithCloseTimeout(closeTimeout),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer func() {
-		if err = c.Close(); err != nil {
-			t.Error(err)
-		}
-	}()
 	select {
 	case <-agent.gc:
 	case <-time.After(time.Millisecond * 200):
 		t.Error("timed out")
 	}
+	// Waiting for next tick, Collect call is stuck until gc is read.
+	time.Sleep(time.Millisecond * 20)
+	start := time.Now()
+	if err = c.Close(); !errors.Is(err, ErrCollectorCloseTimeout) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > closeTimeout*4 {
+		t.Errorf("Close took %s", elapsed)
+	}
+	// Agent is closed after stuck Collect returns.
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-agent.gc:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("agent is not closed")
+		}
+	}
 }
 
 func TestClientCheckInit(t *testing.T) {
@@ -689,7 +728,7 @@ func TestClientFinalizer(t *testing.T) {
 	}
 }
 
-func TestCallbackWaitHandler(*testing.T) {
+func TestCallbackWaitHandler(t *testing.T) {
 	h := callbackWaitHandlerPool.Get().(*callbackWaitHandler) //nolint:forcetypeassert
 	for i := 0; i < 100; i++ {
 		h.setCallback(func(Event) {})
@@ -697,7 +736,9 @@ func TestCallbackWaitHandler(*testing.T) {
 			time.Sleep(time.Microsecond * 100)
 			h.HandleEvent(Event{})
 		}()
-		h.wait()
+		if err := h.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
 	}
 }
 
@@ -982,6 +1023,52 @@ func TestClient_Close(t *testing.T) {
 	})
 }
 
+func TestWithIndicationHandler(t *testing.T) {
+	agent := &TestAgent{
+		e: make(chan Event),
+	}
+	var (
+		indications = make(chan MessageType, 1)
+		unmatched   = make(chan MessageType, 1)
+	)
+	client, createErr := NewClient(noopConnection{},
+		WithAgent(agent),
+		WithHandler(func(e Event) {
+			unmatched <- e.Message.Type
+		}),
+		WithIndicationHandler(func(e Event) {
+			indications <- e.Message.Type
+		}),
+	)
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+	for _, tc := range []struct {
+		typ      MessageType
+		expected chan MessageType
+	}{
+		{typ: MessageType{Method: MethodData, Class: ClassIndication}, expected: indications},
+		{typ: BindingSuccess, expected: unmatched},
+		{typ: BindingRequest, expected: unmatched},
+	} {
+		agent.h(Event{
+			TransactionID: NewTransactionID(),
+			Message:       MustBuild(tc.typ),
+		})
+		select {
+		case typ := <-tc.expected:
+			if typ != tc.typ {
+				t.Errorf("unexpected type %s", typ)
+			}
+		default:
+			t.Errorf("%s is not passed to expected handler", tc.typ)
+		}
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
 func TestClientDefaultHandler(t *testing.T) {
 	agent := &TestAgent{
 		e: make(chan Event),
@@ -1534,3 +1621,1132 @@ func TestClientImmediateTimeout(t *testing.T) {
 	})
 	<-gotReads
 }
+
+func TestWithPreciseTimeouts(t *testing.T) {
+	const rto = 20 * time.Millisecond
+	client, err := NewClient(noopConnection{},
+		WithRTO(rto),
+		WithNoRetransmit,
+		WithPreciseTimeouts(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	if _, ok := client.collector.(noopCollector); !ok {
+		t.Errorf("unexpected collector %T", client.collector)
+	}
+	start := time.Now()
+	var gotErr error
+	if doErr := client.Do(MustBuild(TransactionID, BindingRequest), func(event Event) {
+		gotErr = event.Error
+	}); doErr != nil {
+		t.Fatal(doErr)
+	}
+	if !errors.Is(gotErr, ErrTransactionTimeOut) {
+		t.Errorf("unexpected error: %v", gotErr)
+	}
+	if elapsed := time.Since(start); elapsed < rto {
+		t.Errorf("timed out too early: %s", elapsed)
+	}
+}
+
+func TestTickerCollector(t *testing.T) {
+	t.Run("Close", func(t *testing.T) {
+		collector := newTickerCollector(systemClock(), 0)
+		called := make(chan struct{}, 1)
+		if err := collector.Start(time.Millisecond, func(time.Time) {
+			select {
+			case called <- struct{}{}:
+			default:
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		<-called
+		if err := collector.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("CloseTimeout", func(t *testing.T) {
+		collector := newTickerCollector(systemClock(), time.Millisecond*10)
+		stuck := make(chan struct{})
+		release := make(chan struct{})
+		if err := collector.Start(time.Millisecond, func(time.Time) {
+			select {
+			case stuck <- struct{}{}:
+			default:
+			}
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+		<-stuck
+		if err := collector.Close(); !errors.Is(err, ErrCollectorCloseTimeout) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		close(release)
+		<-collector.done
+	})
+}
+
+func TestClient_Stats(t *testing.T) {
+	response := MustBuild(TransactionID, BindingSuccess)
+	response.Encode()
+	request := MustBuild(response, BindingRequest)
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			panic(closeErr)
+		}
+	}()
+	agent := &manualAgent{}
+	attempt := 0
+	agent.start = func(id [TransactionIDSize]byte, _ time.Time) error {
+		event := Event{TransactionID: id, Message: response}
+		if attempt == 0 {
+			attempt++
+			event = Event{TransactionID: id, Error: ErrTransactionTimeOut}
+		}
+		go agent.h(event)
+
+		return nil
+	}
+	client, err := NewClient(connR,
+		WithAgent(agent),
+		WithCollector(new(manualCollector)),
+		WithRTO(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	gotReads := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1500)
+		for i := 0; i < 2; i++ {
+			if _, readErr := connL.Read(buf); readErr != nil {
+				t.Error(readErr)
+			}
+		}
+		if _, writeErr := connL.Write(response.Raw); writeErr != nil {
+			t.Error(writeErr)
+		}
+		gotReads <- struct{}{}
+	}()
+	if doErr := client.Do(request, func(Event) {}); doErr != nil {
+		t.Fatal(doErr)
+	}
+	<-gotReads
+	// Waiting for client to read the response and to finish retransmission,
+	// which is counted after write.
+	stats := client.Stats()
+	for i := 0; i < 100 && (stats.BytesReceived == 0 || stats.Retransmissions == 0); i++ {
+		time.Sleep(time.Millisecond)
+		stats = client.Stats()
+	}
+	expected := ClientStats{
+		TransactionsStarted:   1,
+		TransactionsSucceeded: 1,
+		Retransmissions:       1,
+		BytesSent:             uint64(2 * len(request.Raw)),
+		BytesReceived:         uint64(len(response.Raw)),
+		RTO:                   time.Second,
+	}
+	if stats != expected {
+		t.Errorf("%+v != %+v", stats, expected)
+	}
+}
+
+func TestClient_TransactionAttempts(t *testing.T) {
+	response := MustBuild(TransactionID, BindingSuccess)
+	request := MustBuild(response, BindingRequest)
+	agent := &manualAgent{}
+	attempts := make(chan int, 2)
+	client, err := NewClient(noopConnection{},
+		WithAgent(agent),
+		WithCollector(new(manualCollector)),
+		WithRTO(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	if rto := client.RTO(); rto != time.Second {
+		t.Errorf("unexpected RTO %s", rto)
+	}
+	agent.start = func(id [TransactionIDSize]byte, _ time.Time) error {
+		go func() {
+			// Waiting for transaction to be registered by client.
+			for i := 0; i < 100; i++ {
+				if n, ok := client.TransactionAttempts(id); ok {
+					attempts <- n
+
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			event := Event{TransactionID: id, Message: response}
+			if len(attempts) == 1 {
+				event = Event{TransactionID: id, Error: ErrTransactionTimeOut}
+			}
+			agent.h(event)
+		}()
+
+		return nil
+	}
+	if doErr := client.Do(request, func(event Event) {
+		if event.Error != nil {
+			t.Error(event.Error)
+		}
+	}); doErr != nil {
+		t.Fatal(doErr)
+	}
+	if first, second := <-attempts, <-attempts; first != 1 || second != 2 {
+		t.Errorf("unexpected attempts: %d, %d", first, second)
+	}
+	if _, ok := client.TransactionAttempts(request.TransactionID); ok {
+		t.Error("transaction should not be in progress")
+	}
+}
+
+func TestWithRetransmitJitter(t *testing.T) {
+	for _, tc := range []struct {
+		fraction, expected float64
+	}{
+		{0.1, 0.1}, {-1, 0}, {2, 1},
+	} {
+		c := &Client{}
+		WithRetransmitJitter(tc.fraction)(c)
+		if c.jitter != tc.expected {
+			t.Errorf("WithRetransmitJitter(%v): %v != %v", tc.fraction, c.jitter, tc.expected)
+		}
+	}
+	now := time.Now()
+	transaction := &clientTransaction{
+		rto:     time.Second,
+		attempt: 1,
+		jitter:  0.1,
+	}
+	var minInterval, maxInterval time.Duration
+	for i := 0; i < 1000; i++ {
+		interval := transaction.nextTimeout(now).Sub(now)
+		if interval < 1800*time.Millisecond || interval > 2200*time.Millisecond {
+			t.Fatalf("interval %s is out of range", interval)
+		}
+		if i == 0 || interval < minInterval {
+			minInterval = interval
+		}
+		if interval > maxInterval {
+			maxInterval = interval
+		}
+	}
+	if minInterval == maxInterval {
+		t.Error("interval should be randomized")
+	}
+}
+
+// refusedOnceConn fails first read with ECONNREFUSED, like connected UDP
+// socket after ICMP port unreachable.
+type refusedOnceConn struct {
+	net.Conn
+	refused atomic.Bool
+}
+
+func (c *refusedOnceConn) Read(b []byte) (int, error) {
+	if c.refused.CompareAndSwap(false, true) {
+		return 0, &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("recvfrom", syscall.ECONNREFUSED)}
+	}
+
+	return c.Conn.Read(b)
+}
+
+func TestWithConnErrorHandler(t *testing.T) {
+	t.Run("PeerClosed", func(t *testing.T) {
+		connL, connR := net.Pipe()
+		gotErr := make(chan error, 1)
+		client, err := NewClient(connR, WithConnErrorHandler(func(err error) {
+			gotErr <- err
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Fatal(closeErr)
+		}
+		select {
+		case connErr := <-gotErr:
+			if !errors.Is(connErr, io.EOF) || !IsNetworkError(connErr) {
+				t.Errorf("unexpected error: %v", connErr)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("handler is not called")
+		}
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	})
+	t.Run("Transient", func(t *testing.T) {
+		connL, connR := net.Pipe()
+		defer func() {
+			if closeErr := connL.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		gotErr := make(chan error, 1)
+		client, err := NewClient(&refusedOnceConn{Conn: connR}, WithConnErrorHandler(func(err error) {
+			gotErr <- err
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case connErr := <-gotErr:
+			if !errors.Is(connErr, syscall.ECONNREFUSED) {
+				t.Errorf("unexpected error: %v", connErr)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("handler is not called")
+		}
+		if !client.Connected() {
+			t.Error("transient error should not fail connection")
+		}
+		go func() {
+			buf := make([]byte, 1500)
+			n, readErr := connL.Read(buf)
+			if readErr != nil {
+				return
+			}
+			req := new(Message)
+			if decodeErr := Decode(buf[:n], req); decodeErr != nil {
+				t.Error(decodeErr)
+
+				return
+			}
+			_, _ = connL.Write(MustBuild(req, BindingSuccess).Raw)
+		}()
+		if err = client.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			if e.Error != nil {
+				t.Errorf("unexpected error: %v", e.Error)
+			}
+		}); err != nil {
+			t.Error(err)
+		}
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	})
+	t.Run("ClientClosed", func(t *testing.T) {
+		connL, connR := net.Pipe()
+		defer func() {
+			if closeErr := connL.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		client, err := NewClient(connR, WithConnErrorHandler(func(err error) {
+			t.Errorf("unexpected call: %v", err)
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	})
+}
+
+func TestClient_SetConnection(t *testing.T) {
+	oldL, oldR := net.Pipe()
+	newL, newR := net.Pipe()
+	defer func() {
+		if closeErr := newL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	client, err := NewClient(oldR, WithConnErrorHandler(func(err error) {
+		t.Errorf("unexpected connection error: %v", err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := MustBuild(TransactionID, BindingRequest)
+	response := MustBuild(request, BindingSuccess)
+	go func() {
+		buf := make([]byte, 1500)
+		if _, readErr := oldL.Read(buf); readErr != nil {
+			t.Error(readErr)
+		}
+		if setErr := client.SetConnection(newR); setErr != nil {
+			t.Error(setErr)
+		}
+		// Previous connection should be closed.
+		if _, readErr := oldL.Read(buf); !errors.Is(readErr, io.EOF) {
+			t.Errorf("unexpected error: %v", readErr)
+		}
+		if _, writeErr := newL.Write(response.Raw); writeErr != nil {
+			t.Error(writeErr)
+		}
+	}()
+	if doErr := client.Do(request, func(event Event) {
+		if event.Error != nil {
+			t.Error(event.Error)
+		}
+	}); doErr != nil {
+		t.Fatal(doErr)
+	}
+	if setErr := client.SetConnection(nil); !errors.Is(setErr, ErrNoConnection) {
+		t.Errorf("unexpected error: %v", setErr)
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+	if setErr := client.SetConnection(newR); !errors.Is(setErr, ErrClientClosed) {
+		t.Errorf("unexpected error: %v", setErr)
+	}
+}
+
+func TestWithClonedEvents(t *testing.T) {
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	client, err := NewClient(connR, WithClonedEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, readErr := connL.Read(buf)
+			if readErr != nil {
+				return
+			}
+			request := new(Message)
+			if decodeErr := Decode(buf[:n], request); decodeErr != nil {
+				t.Error(decodeErr)
+
+				return
+			}
+			response := MustBuild(request, BindingSuccess, NewSoftware(string(request.TransactionID[:4])))
+			if _, writeErr := connL.Write(response.Raw); writeErr != nil {
+				return
+			}
+		}
+	}()
+	var retained []*Message
+	for i := 0; i < 2; i++ {
+		if doErr := client.Do(MustBuild(TransactionID, BindingRequest), func(event Event) {
+			if event.Error != nil {
+				t.Error(event.Error)
+
+				return
+			}
+			retained = append(retained, event.Message)
+		}); doErr != nil {
+			t.Fatal(doErr)
+		}
+	}
+	if len(retained) != 2 || retained[0] == retained[1] {
+		t.Fatal("messages should be distinct")
+	}
+	for _, m := range retained {
+		var software Software
+		if getErr := software.GetFrom(m); getErr != nil {
+			t.Fatal(getErr)
+		}
+		if string(software) != string(m.TransactionID[:4]) {
+			t.Errorf("message %s is overwritten", m)
+		}
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
+func TestClient_DoContext(t *testing.T) {
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	stopped := make(chan Event, 1)
+	agent := &manualAgent{}
+	client, err := NewClient(connR,
+		WithAgent(agent),
+		WithCollector(&manualCollector{f: func(time.Time) {}}),
+		WithNoRetransmit,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.start = func([TransactionIDSize]byte, time.Time) error {
+		return nil
+	}
+	agent.stop = func(id [TransactionIDSize]byte) error {
+		stopped <- Event{TransactionID: id, Error: ErrTransactionStopped}
+
+		return nil
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, readErr := connL.Read(buf); readErr != nil {
+				return
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	m := MustBuild(TransactionID, BindingRequest)
+	if doErr := client.DoContext(ctx, m, func(Event) {
+		t.Error("should not be called")
+	}); !errors.Is(doErr, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", doErr)
+	}
+	select {
+	case e := <-stopped:
+		if e.TransactionID != m.TransactionID {
+			t.Error("unexpected transaction stopped")
+		}
+	default:
+		t.Error("transaction is not stopped")
+	}
+	if _, ok := client.TransactionAttempts(m.TransactionID); ok {
+		t.Error("transaction should be removed")
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
+func TestClient_NoRetransmitOpt(t *testing.T) {
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	clock := &manualClock{current: time.Now()}
+	agent := &manualAgent{}
+	starts := 0
+	agent.start = func(id [TransactionIDSize]byte, deadline time.Time) error {
+		starts++
+		if expected := clock.current.Add((defaultMaxAttempts + 1) * time.Second); !deadline.Equal(expected) {
+			t.Errorf("deadline %s, expected %s", deadline, expected)
+		}
+		go agent.h(Event{
+			TransactionID: id,
+			Error:         ErrTransactionTimeOut,
+		})
+
+		return nil
+	}
+	client, err := NewClient(connR,
+		WithAgent(agent),
+		WithClock(clock),
+		WithCollector(new(manualCollector)),
+		WithRTO(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, readErr := connL.Read(buf); readErr != nil {
+				return
+			}
+		}
+	}()
+	if doErr := client.Do(MustBuild(TransactionID, BindingRequest), func(event Event) {
+		if !errors.Is(event.Error, ErrTransactionTimeOut) {
+			t.Errorf("unexpected error: %v", event.Error)
+		}
+	}, NoRetransmitOpt); doErr != nil {
+		t.Fatal(doErr)
+	}
+	if starts != 1 {
+		t.Errorf("started %d times, expected 1", starts)
+	}
+	if stats := client.Stats(); stats.Retransmissions != 0 {
+		t.Errorf("retransmitted %d times", stats.Retransmissions)
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
+func TestClient_DoSetters(t *testing.T) {
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	client, err := NewClient(connR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, readErr := connL.Read(buf)
+			if readErr != nil {
+				return
+			}
+			request := new(Message)
+			if decodeErr := Decode(buf[:n], request); decodeErr != nil {
+				t.Error(decodeErr)
+
+				return
+			}
+			if checkErr := Fingerprint.Check(request); checkErr != nil {
+				t.Error(checkErr)
+			}
+			if _, writeErr := connL.Write(MustBuild(request, BindingSuccess).Raw); writeErr != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		if doErr := client.DoSetters(func(event Event) {
+			if event.Error != nil {
+				t.Error(event.Error)
+			}
+		}, TransactionID, BindingRequest, Fingerprint); doErr != nil {
+			t.Fatal(doErr)
+		}
+	}
+	if doErr := client.DoSetters(func(Event) {
+		t.Error("should not be called")
+	}, TransactionID, errReturner{Err: errTError}); !errors.Is(doErr, errTError) {
+		t.Errorf("unexpected error: %v", doErr)
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
+func TestClient_ConnInfo(t *testing.T) {
+	t.Run("Addr", func(t *testing.T) {
+		connL, connR := net.Pipe()
+		defer func() {
+			if closeErr := connL.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		client, err := NewClient(connR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.LocalAddr() != connR.LocalAddr() {
+			t.Error("unexpected local addr")
+		}
+		if client.RemoteAddr() != connR.RemoteAddr() {
+			t.Error("unexpected remote addr")
+		}
+		if !client.Connected() {
+			t.Error("should be connected")
+		}
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+		if client.Connected() {
+			t.Error("closed client should not be connected")
+		}
+	})
+	t.Run("NoAddr", func(t *testing.T) {
+		client, err := NewClient(noopConnection{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.LocalAddr() != nil || client.RemoteAddr() != nil {
+			t.Error("unexpected addr")
+		}
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	})
+	t.Run("ConnFailed", func(t *testing.T) {
+		connL, connR := net.Pipe()
+		gotErr := make(chan error, 1)
+		client, err := NewClient(connR, WithConnErrorHandler(func(err error) {
+			gotErr <- err
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Fatal(closeErr)
+		}
+		select {
+		case <-gotErr:
+		case <-time.After(time.Second):
+			t.Fatal("handler is not called")
+		}
+		if client.Connected() {
+			t.Error("should not be connected after read failure")
+		}
+		connL, connR = net.Pipe()
+		defer func() {
+			if closeErr := connL.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		if err = client.SetConnection(connR); err != nil {
+			t.Fatal(err)
+		}
+		if !client.Connected() {
+			t.Error("should be connected after SetConnection")
+		}
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	})
+}
+
+func TestClient_RTT(t *testing.T) {
+	response := MustBuild(TransactionID, BindingSuccess)
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	clock := &manualClock{current: time.Now()}
+	agent := &manualAgent{}
+	attempt := 0
+	agent.start = func(id [TransactionIDSize]byte, _ time.Time) error {
+		attempt++
+		if attempt == 1 {
+			clock.Add(100 * time.Millisecond)
+			go agent.h(Event{
+				TransactionID: id,
+				Error:         ErrTransactionTimeOut,
+			})
+		} else {
+			clock.Add(30 * time.Millisecond)
+		}
+
+		return nil
+	}
+	client, err := NewClient(connR,
+		WithAgent(agent),
+		WithClock(clock),
+		WithCollector(new(manualCollector)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for reads := 1; ; reads++ {
+			if _, readErr := connL.Read(buf); readErr != nil {
+				return
+			}
+			if reads == 2 {
+				// Responding to retransmission.
+				agent.h(Event{
+					TransactionID: response.TransactionID,
+					Message:       response,
+				})
+			}
+		}
+	}()
+	if doErr := client.Do(MustBuild(response, BindingRequest), func(event Event) {
+		if event.Error != nil {
+			t.Fatal(event.Error)
+		}
+		if event.RTT != 130*time.Millisecond {
+			t.Errorf("unexpected RTT %s", event.RTT)
+		}
+		if event.AttemptRTT != 30*time.Millisecond {
+			t.Errorf("unexpected attempt RTT %s", event.AttemptRTT)
+		}
+	}); doErr != nil {
+		t.Fatal(doErr)
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
+func TestClient_ErrorResponseEvent(t *testing.T) {
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	client, err := NewClient(connR, WithCollector(new(manualCollector)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alternate := &AlternateServer{IP: net.IPv4(127, 0, 0, 2), Port: 3478}
+	go func() {
+		buf := make([]byte, 1500)
+		for reads := 1; ; reads++ {
+			n, readErr := connL.Read(buf)
+			if readErr != nil {
+				return
+			}
+			req := new(Message)
+			if decodeErr := Decode(buf[:n], req); decodeErr != nil {
+				t.Error(decodeErr)
+
+				return
+			}
+			res := MustBuild(req, BindingSuccess)
+			if reads == 1 {
+				res = MustBuild(req, BindingError, CodeTryAlternate,
+					NewRealm("realm"), NewNonce("nonce"), alternate,
+				)
+			}
+			if _, writeErr := connL.Write(res.Raw); writeErr != nil {
+				return
+			}
+		}
+	}()
+	called := false
+	if err = client.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		called = true
+		if e.ErrorCode == nil || e.ErrorCode.Code != CodeTryAlternate {
+			t.Errorf("unexpected ERROR-CODE %v", e.ErrorCode)
+		}
+		if e.Realm.String() != "realm" || e.Nonce.String() != "nonce" {
+			t.Errorf("unexpected REALM %q or NONCE %q", e.Realm, e.Nonce)
+		}
+		if e.AlternateServer == nil || !e.AlternateServer.IP.Equal(alternate.IP) || e.AlternateServer.Port != alternate.Port {
+			t.Errorf("unexpected ALTERNATE-SERVER %v", e.AlternateServer)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("handler not called")
+	}
+	if err = client.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.ErrorCode != nil || e.Realm != nil || e.Nonce != nil || e.AlternateServer != nil {
+			t.Errorf("unexpected error response attributes in %+v", e)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	connL, connR := net.Pipe()
+	defer func() {
+		if closeErr := connL.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	clock := &manualClock{current: time.Now()}
+	client, err := NewClient(connR,
+		WithClock(clock),
+		WithCollector(new(manualCollector)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for reads := 1; ; reads++ {
+			n, readErr := connL.Read(buf)
+			if readErr != nil {
+				return
+			}
+			req := new(Message)
+			if decodeErr := Decode(buf[:n], req); decodeErr != nil {
+				t.Error(decodeErr)
+
+				return
+			}
+			if reads > 2 {
+				continue // No response to request with done context.
+			}
+			clock.Add(25 * time.Millisecond)
+			res := MustBuild(req, BindingSuccess)
+			if reads == 2 {
+				res = MustBuild(req, BindingError, CodeUnauthorized)
+			}
+			if _, writeErr := connL.Write(res.Raw); writeErr != nil {
+				return
+			}
+		}
+	}()
+	rtt, err := client.Ping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtt != 25*time.Millisecond {
+		t.Errorf("unexpected RTT %s", rtt)
+	}
+	rtt, err = client.Ping(context.Background())
+	if !errors.Is(err, ErrErrorResponse) || rtt != 25*time.Millisecond {
+		t.Errorf("unexpected RTT %s, error %v", rtt, err)
+	}
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := client.Ping(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
+	}
+}
+
+func TestWithKernelTimestamps_NotSupported(t *testing.T) {
+	connL, connR := net.Pipe()
+	defer connL.Close() //nolint:errcheck
+	defer connR.Close() //nolint:errcheck
+	if _, err := NewClient(connR, WithKernelTimestamps()); !errors.Is(err, ErrKernelTimestampsNotSupported) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	var stream []byte
+	for i := 0; i < 3; i++ {
+		m := MustBuild(TransactionID, BindingSuccess, NewSoftware(fmt.Sprint("software ", i)))
+		stream = append(stream, m.Raw...)
+	}
+	// Byte by byte with timeout after each byte, as partially read message
+	// should be kept on errors.
+	reader := &streamReader{r: iotest.TimeoutReader(iotest.OneByteReader(bytes.NewReader(stream)))}
+	buf := make([]byte, 1024)
+	for i := 0; i < 3; i++ {
+		n, err := reader.Read(buf)
+		for errors.Is(err, iotest.ErrTimeout) {
+			n, err = reader.Read(buf)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := new(Message)
+		if _, err = m.Write(buf[:n]); err != nil {
+			t.Fatal(err)
+		}
+		software, err := Parse[Software](m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprint("software ", i); software.String() != expected {
+			t.Errorf("unexpected software %q, expected %q", software, expected)
+		}
+	}
+	if _, err := reader.Read(buf); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error %v", err)
+	}
+	t.Run("ShortBuffer", func(t *testing.T) {
+		reader := &streamReader{r: bytes.NewReader(append(stream[:0:0], stream...))}
+		short := make([]byte, messageHeaderSize)
+		if n, err := reader.Read(short); err != nil || n != messageHeaderSize {
+			t.Fatalf("unexpected read %d, %v", n, err)
+		}
+		// Rest of truncated message should be skipped.
+		n, err := reader.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsMessage(buf[:n]) || n != len(stream)/3 {
+			t.Errorf("unexpected message of %d bytes", n)
+		}
+	})
+	t.Run("DataWithError", func(t *testing.T) {
+		// Messages read along with EOF should be returned before it.
+		reader := &streamReader{r: iotest.DataErrReader(bytes.NewReader(stream))}
+		for i := 0; i < 3; i++ {
+			if n, err := reader.Read(buf); err != nil || n != len(stream)/3 {
+				t.Fatalf("unexpected read %d, %v", n, err)
+			}
+		}
+		if _, err := reader.Read(buf); !errors.Is(err, io.EOF) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("ChannelData", func(t *testing.T) {
+		// Channel 0x4000 with 5 bytes of data padded to 8 bytes.
+		channelData := []byte{0x40, 0x00, 0x00, 0x05, 1, 2, 3, 4, 5, 0, 0, 0}
+		size := len(stream) / 3
+		data := append(append(append([]byte{}, stream[:size]...), channelData...), stream[size:2*size]...)
+		reader := &streamReader{r: iotest.OneByteReader(bytes.NewReader(data))}
+		for _, expected := range []int{size, len(channelData), size} {
+			n, err := reader.Read(buf)
+			if err != nil || n != expected {
+				t.Fatalf("unexpected read %d, %v, expected %d bytes", n, err, expected)
+			}
+		}
+	})
+}
+
+func TestClient_Stream(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	const transactions = 3
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			serverErr <- acceptErr
+
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		// Responding to all requests with single write, so responses are
+		// coalesced into single segment.
+		var (
+			reader    = &streamReader{r: conn}
+			buf       = make([]byte, 1024)
+			responses []byte
+		)
+		for i := 0; i < transactions; i++ {
+			n, readErr := reader.Read(buf)
+			if readErr != nil {
+				serverErr <- readErr
+
+				return
+			}
+			req := new(Message)
+			if _, readErr = req.Write(buf[:n]); readErr != nil {
+				serverErr <- readErr
+
+				return
+			}
+			responses = append(responses, MustBuild(req, BindingSuccess).Raw...)
+		}
+		_, writeErr := conn.Write(responses)
+		serverErr <- writeErr
+		// Waiting for client to close connection.
+		_, _ = conn.Read(buf)
+	}()
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(conn, WithStreamFraming())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < transactions; i++ {
+		wg.Add(1)
+		if err := client.Start(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			defer wg.Done()
+			if e.Error != nil {
+				t.Error(e.Error)
+			}
+		}, NoRetransmitOpt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+}
+
+func TestClient_SetDeadline(t *testing.T) {
+	t.Run("Precise", func(t *testing.T) {
+		const (
+			rto      = 20 * time.Millisecond
+			extended = 10 * rto
+		)
+		client, err := NewClient(noopConnection{},
+			WithRTO(rto),
+			WithNoRetransmit,
+			WithPreciseTimeouts(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if closeErr := client.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		m := MustBuild(TransactionID, BindingRequest)
+		start := time.Now()
+		done := make(chan error, 1)
+		if err = client.Start(m, func(event Event) {
+			done <- event.Error
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err = client.SetDeadline(m.TransactionID, start.Add(extended)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case gotErr := <-done:
+			if !errors.Is(gotErr, ErrTransactionTimeOut) {
+				t.Errorf("unexpected error: %v", gotErr)
+			}
+		case <-time.After(5 * extended):
+			// Timer is not rescheduled, so nothing collects transaction.
+			t.Fatal("transaction is not timed out")
+		}
+		if elapsed := time.Since(start); elapsed < extended {
+			t.Errorf("timed out before extended deadline: %s", elapsed)
+		}
+		if err = client.SetDeadline(m.TransactionID, time.Now()); !errors.Is(err, ErrTransactionNotExists) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("NotSupported", func(t *testing.T) {
+		client, err := NewClient(noopConnection{},
+			WithAgent(&manualAgent{}),
+			WithCollector(new(manualCollector)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if closeErr := client.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		if err = client.SetDeadline(transactionID{}, time.Now()); !errors.Is(err, ErrSetDeadlineNotSupported) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Closed", func(t *testing.T) {
+		client, err := NewClient(noopConnection{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err = client.SetDeadline(transactionID{}, time.Now()); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -6,44 +6,102 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"errors"
 	"flag"
+	"fmt"
 	"log"
-	mathRand "math/rand"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
-	"sync/atomic"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pion/stun/v3"
+	"github.com/pion/stun/v3/stunbench"
 )
 
 var (
 	workers    = flag.Int("w", runtime.GOMAXPROCS(0), "concurrent workers")           //nolint:gochecknoglobals
-	uriStr     = flag.String("uri", "stun:localhost:3478", "URI of STUN server")      //nolint:gochecknoglobals
 	duration   = flag.Duration("d", time.Minute, "benchmark duration")                //nolint:gochecknoglobals
 	cpuProfile = flag.String("cpuprofile", "", "file output of pprof cpu profile")    //nolint:gochecknoglobals
 	memProfile = flag.String("memprofile", "", "file output of pprof memory profile") //nolint:gochecknoglobals
 	realRand   = flag.Bool("crypt", false, "use crypto/rand as random source")        //nolint:gochecknoglobals
+	insecure   = flag.Bool("insecure", false, "skip TLS certificate verification")    //nolint:gochecknoglobals
+	rate       = flag.Int("rate", 0, "open-loop requests per second")                 //nolint:gochecknoglobals
 )
 
+// defaultURI is used if no -uri flag is provided.
+const defaultURI = "stun:localhost:3478"
+
+// uriList is flag.Value of repeated -uri flag.
+type uriList []string
+
+var uriStrs uriList //nolint:gochecknoglobals
+
+func (l *uriList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *uriList) Set(s string) error {
+	*l = append(*l, s)
+
+	return nil
+}
+
+// target is STUN server with its share of workers and rate.
+type target struct {
+	uri     *stun.URI
+	workers int
+	rate    int
+	stats   stunbench.Stats
+	err     error
+}
+
+// share returns i-th of k shares of n, distributing remainder to the first
+// shares, so shares sum to n.
+func share(n, i, k int) int {
+	s := n / k
+	if i < n%k {
+		s++
+	}
+
+	return s
+}
+
 func main() { //nolint:gocognit,cyclop
+	flag.Var(&uriStrs, "uri",
+		fmt.Sprintf(
+			"URI of STUN server, e.g. stuns:host or turn:host?transport=tcp for TCP, "+
+				"repeat to split workers between servers (default %q)", defaultURI,
+		),
+	)
 	flag.Parse()
-	uri, err := stun.ParseURI(*uriStr)
-	if err != nil {
-		log.Fatalf("Failed to parse URI '%s': %s", *uriStr, err)
+	if len(uriStrs) == 0 {
+		uriStrs = uriList{defaultURI}
+	}
+	// Every target gets at least one worker and, in open loop, at least
+	// one request per second.
+	if *workers < len(uriStrs) {
+		log.Fatalf("Need at least one worker per URI, got %d for %d URIs", *workers, len(uriStrs))
+	}
+	if *rate < 0 || (*rate > 0 && *rate < len(uriStrs)) {
+		log.Fatalf("Need zero rate or at least one request per second per URI, got %d for %d URIs", *rate, len(uriStrs))
+	}
+	targets := make([]*target, 0, len(uriStrs))
+	for i, uriStr := range uriStrs {
+		uri, err := stun.ParseURI(uriStr)
+		if err != nil {
+			log.Fatalf("Failed to parse URI '%s': %s", uriStr, err)
+		}
+		targets = append(targets, &target{
+			uri:     uri,
+			workers: share(*workers, i, len(uriStrs)),
+			rate:    share(*rate, i, len(uriStrs)),
+		})
 	}
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt)
-	start := time.Now()
-	var (
-		request    int64
-		requestOK  int64
-		requestErr int64
-	)
 	if *cpuProfile != "" {
 		f, createErr := os.Create(*cpuProfile)
 		if createErr != nil {
@@ -78,6 +136,7 @@ func main() { //nolint:gocognit,cyclop
 		}()
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
 	go func() {
 		for sig := range signals {
 			log.Printf("Stopping on %s", sig)
@@ -87,50 +146,78 @@ func main() { //nolint:gocognit,cyclop
 	if *realRand {
 		log.Print("Using crypto/rand as random source for transaction id")
 	}
-	for i := 0; i < *workers; i++ {
-		client, clientErr := stun.DialURI(uri, &stun.DialConfig{})
-		if clientErr != nil {
-			log.Panicf("Failed to create client: %s", clientErr)
+	if len(targets) > 1 {
+		if *rate > 0 {
+			log.Printf("Starting %d clients at %d requests per second over %d targets", *workers, *rate, len(targets))
+		} else {
+			log.Printf("Starting %d workers over %d targets", *workers, len(targets))
+		}
+	}
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		dialConfig := &stun.DialConfig{}
+		dialConfig.TLSConfig.InsecureSkipVerify = *insecure //nolint:gosec
+		if t.uri.Proto == stun.ProtoTypeTCP {
+			// Open loop puts many concurrent transactions on each stream.
+			dialConfig.ClientOptions = []stun.ClientOption{stun.WithStreamFraming()}
+		}
+		if t.rate > 0 {
+			log.Printf("Starting %d clients at %d requests per second, %s over %s", t.workers, t.rate, t.uri, t.uri.Proto)
+		} else {
+			log.Printf("Starting %d workers, %s over %s", t.workers, t.uri, t.uri.Proto)
+		}
+		wg.Add(1)
+		go func(t *target) {
+			defer wg.Done()
+			t.stats, t.err = stunbench.Run(ctx, stunbench.Config{
+				Dial: func() (*stun.Client, error) {
+					return stun.DialURI(t.uri, dialConfig)
+				},
+				Workers:    t.workers,
+				Rate:       t.rate,
+				OpenLoop:   t.rate > 0,
+				CryptoRand: *realRand,
+				// Responses over TCP and TLS are not lost, so
+				// retransmissions would only load the stream.
+				NoRetransmit: t.uri.Proto == stun.ProtoTypeTCP,
+				OnError: func(err error) {
+					log.Printf("Failed STUN transaction to %s: %s", t.uri, err)
+				},
+			})
+		}(t)
+	}
+	wg.Wait()
+	var total stunbench.Stats
+	for _, t := range targets {
+		if t.err != nil {
+			log.Printf("Failed to run benchmark for %s: %s", t.uri, t.err)
 		}
-		go func() {
-			req := stun.New()
-			for {
-				if *realRand {
-					if _, err := rand.Read(req.TransactionID[:]); err != nil { //nolint:gosec
-						log.Fatalf("Failed to generate transaction ID: %s", err)
-					}
-				} else {
-					mathRand.Read(req.TransactionID[:]) //nolint:gosec
-				}
-				req.Type = stun.BindingRequest
-				req.WriteHeader()
-				atomic.AddInt64(&request, 1)
-				if doErr := client.Do(req, func(event stun.Event) {
-					if event.Error != nil {
-						if !errors.Is(event.Error, stun.ErrTransactionTimeOut) {
-							log.Printf("Failed STUN transaction: %s", event.Error)
-						}
-						atomic.AddInt64(&requestErr, 1)
+		if len(targets) > 1 {
+			log.Printf("Target %s:", t.uri)
+		}
+		printStats(t.stats)
+		total.Requests += t.stats.Requests
+		total.Succeeded += t.stats.Succeeded
+		total.Failed += t.stats.Failed
+		if t.stats.Elapsed > total.Elapsed {
+			total.Elapsed = t.stats.Elapsed
+		}
+	}
+	if len(targets) > 1 {
+		log.Printf("All targets: RPS %v, errors %d, total %d", int(total.RPS()), total.Failed, total.Requests)
+	}
+}
 
-						return
-					}
-					atomic.AddInt64(&requestOK, 1)
-				}); doErr != nil {
-					if !errors.Is(doErr, stun.ErrTransactionExists) {
-						log.Printf("Failed STUN transaction: %s", doErr)
-					}
-					atomic.AddInt64(&requestErr, 1)
-				}
-			}
-		}()
+func printStats(stats stunbench.Stats) {
+	log.Printf("RPS: %v", int(stats.RPS()))
+	log.Printf("Latency: mean %s, p50 %s, p90 %s, p99 %s, max %s",
+		stats.Latency.Mean, stats.Latency.P50, stats.Latency.P90, stats.Latency.P99, stats.Latency.Max,
+	)
+	if stats.Failed != 0 {
+		log.Printf("Errors: %d", stats.Failed)
 	}
-	log.Print("Workers started")
-	<-ctx.Done()
-	stop := time.Now()
-	rps := int(float64(atomic.LoadInt64(&requestOK)) / stop.Sub(start).Seconds())
-	log.Printf("RPS: %v", rps)
-	if reqErr := atomic.LoadInt64(&requestErr); requestErr != 0 {
-		log.Printf("Errors: %d", reqErr)
+	if stats.Redials != 0 {
+		log.Printf("Redials: %d", stats.Redials)
 	}
-	log.Printf("Total: %d", atomic.LoadInt64(&request))
+	log.Printf("Total: %d", stats.Requests)
 }
@@ -40,8 +40,8 @@ func main() {
 			log.Fatalf("Failed STUN transaction: %s", res.Error)
 		}
 
-		var xorAddr stun.XORMappedAddress
-		if getErr := xorAddr.GetFrom(res.Message); getErr != nil {
+		xorAddr, getErr := stun.Parse[stun.XORMappedAddress](res.Message)
+		if getErr != nil {
 			log.Fatalf("Failed to get XOR-MAPPED-ADDRESS: %s", getErr)
 		}
 
@@ -6,30 +6,128 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/pion/stun/v3"
 )
 
+const (
+	messageHeaderSize     = 20
+	channelDataHeaderSize = 4
+	channelDataPadding    = 4
+)
+
+var errUnknownFormat = errors.New("neither STUN message nor TURN ChannelData")
+
 func main() {
+	all := flag.Bool("all", false, "decode all concatenated messages in input")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", "stun-decode")
 		fmt.Fprintln(os.Stderr, "stun-decode AAEAHCESpEJML0JTQWsyVXkwcmGALwAWaHR0cDovL2xvY2FsaG9zdDozMDAwLwAA")
-		fmt.Fprintln(os.Stderr, "First argument must be a base64.StdEncoding-encoded message")
+		fmt.Fprintln(os.Stderr, "First argument must be a base64.StdEncoding or hex encoded message")
+		fmt.Fprintln(os.Stderr, "Hex may contain whitespace and colons, e.g. Wireshark \"Copy as Hex Stream\"")
+		fmt.Fprintln(os.Stderr, "TURN ChannelData is detected and printed as channel number and length")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
-	data, err := base64.StdEncoding.DecodeString(flag.Arg(0))
+	data, err := decodeInput(flag.Arg(0))
+	if err != nil {
+		log.Fatalln("Unable to decode input:", err)
+	}
+	for len(data) > 0 {
+		n, err := decode(data)
+		if err != nil {
+			log.Fatalln("Unable to decode message:", err)
+		}
+		if !*all {
+			return
+		}
+		data = data[n:]
+	}
+}
+
+// decodeInput decodes hex or base64 encoded input, detecting encoding.
+//
+// Input is treated as hex if it consists only of hex digits, optionally
+// separated by whitespace or colons, and decodes to STUN message or TURN
+// ChannelData. Otherwise it is decoded as base64.
+func decodeInput(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	stripped := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\r', ':':
+			return -1
+		default:
+			return r
+		}
+	}, s)
+	stripped = strings.TrimPrefix(strings.TrimPrefix(stripped, "0x"), "0X")
+	data, hexErr := hex.DecodeString(stripped)
+	if hexErr == nil && (stun.IsMessage(data) || isChannelData(data)) {
+		return data, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil && hexErr == nil {
+		// Valid hex that is neither STUN nor ChannelData, let decode report it.
+		return hex.DecodeString(stripped)
+	}
 	if err != nil {
-		log.Fatalln("Unable to decode bas64 value:", err)
+		return nil, fmt.Errorf("neither hex (%w) nor base64 (%w)", hexErr, err)
+	}
+
+	return data, nil
+}
+
+// isChannelData reports whether data starts with TURN ChannelData header.
+func isChannelData(data []byte) bool {
+	// The first two bits of channel number are 0b01.
+	return len(data) >= channelDataHeaderSize && data[0]&0xc0 == 0x40
+}
+
+// decode prints first STUN message or TURN ChannelData in data,
+// returning number of bytes it occupies.
+func decode(data []byte) (int, error) {
+	if !stun.IsMessage(data) {
+		return decodeChannelData(data)
 	}
 	m := new(stun.Message)
 	m.Raw = data
-	if err = m.Decode(); err != nil {
-		log.Fatalln("Unable to decode message:", err)
+	if err := m.Decode(); err != nil {
+		return 0, err
 	}
 	fmt.Println(m)
+
+	return messageHeaderSize + int(m.Length), nil
+}
+
+// decodeChannelData prints channel number and length of TURN ChannelData
+// message, see RFC 8656 Section 12.4.
+func decodeChannelData(data []byte) (int, error) {
+	if !isChannelData(data) {
+		return 0, errUnknownFormat
+	}
+	var (
+		channel = binary.BigEndian.Uint16(data[0:2])
+		length  = int(binary.BigEndian.Uint16(data[2:4]))
+		size    = channelDataHeaderSize + length
+	)
+	if len(data) < size {
+		return 0, fmt.Errorf("%w: ChannelData length %d exceeds %d remaining bytes",
+			errUnknownFormat, length, len(data)-channelDataHeaderSize,
+		)
+	}
+	fmt.Printf("ChannelData channel=0x%x length=%d\n", channel, length)
+	// Over TCP, ChannelData is padded to a multiple of four bytes.
+	if padded := (size + channelDataPadding - 1) / channelDataPadding * channelDataPadding; padded <= len(data) {
+		size = padded
+	}
+
+	return size, nil
 }
@@ -138,11 +138,11 @@ func main() { //nolint:cyclop
 		if res.Error != nil {
 			log.Panicf("Failed STUN transaction: %s", res.Error)
 		}
-		var xorAddr stun.XORMappedAddress
-		if getErr := xorAddr.GetFrom(res.Message); getErr != nil {
+		xorAddr, getErr := stun.Parse[stun.XORMappedAddress](res.Message)
+		if getErr != nil {
 			log.Panicf("Failed to get XOR-MAPPED-ADDRESS: %s", getErr)
 		}
-		copyAddr(&gotAddr, xorAddr)
+		copyAddr(&gotAddr, *xorAddr)
 	}); err != nil {
 		log.Panicf("Failed STUN transaction: %s", err)
 	}
@@ -1,34 +1,22 @@
 // SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
 // SPDX-License-Identifier: MIT
 
-// This package implements RFC5780's tests:
+// This package runs RFC5780's tests implemented by stunnat package:
 // - 4.3.  Determining NAT Mapping Behavior
 // - 4.4.  Determining NAT Filtering Behavior
 package main
 
 import (
-	"errors"
+	"encoding/json"
 	"flag"
-	"net"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/pion/logging"
-	"github.com/pion/stun/v3"
+	"github.com/pion/stun/v3/stunnat"
 )
 
-type stunServerConn struct {
-	conn        net.PacketConn
-	LocalAddr   net.Addr
-	RemoteAddr  *net.UDPAddr
-	OtherAddr   *net.UDPAddr
-	messageChan chan *stun.Message
-}
-
-func (c *stunServerConn) Close() error {
-	return c.conn.Close()
-}
-
 var (
 	//nolint:gochecknoglobals
 	addrStrPtr = flag.String("server", "stun.voipgate.com:3478", "STUN server address")
@@ -37,18 +25,34 @@ var (
 	//nolint:gochecknoglobals
 	verbose = flag.Int("verbose", 1, "the verbosity level")
 	//nolint:gochecknoglobals
+	jsonOutput = flag.Bool("json", false, "print results as JSON to stdout, logging to stderr")
+	//nolint:gochecknoglobals
+	showProgress = flag.Bool("progress", false, "print progress of each test phase to stderr")
+	//nolint:gochecknoglobals
+	watchInterval = flag.Duration("watch", 0, "re-run tests with given interval, logging changes, 0 to run once")
+	//nolint:gochecknoglobals
 	log logging.LeveledLogger
 )
 
-const (
-	messageHeaderSize = 20
-)
+// result is NAT classification with ICE recommendation.
+type result struct {
+	PublicIP       string           `json:"public_ip,omitempty"` // from mapping test, if any
+	Mapping        stunnat.Behavior `json:"mapping"`
+	Filtering      stunnat.Behavior `json:"filtering"`
+	Recommendation string           `json:"recommendation"`
+}
 
-var (
-	errResponseMessage = errors.New("error reading from response message channel")
-	errTimedOut        = errors.New("timed out waiting for response")
-	errNoOtherAddress  = errors.New("no OTHER-ADDRESS in message")
-)
+// printProgress is stunnat.Observer that prints progress to stderr.
+func printProgress(p stunnat.Progress) {
+	switch p.Phase {
+	case stunnat.PhaseSent:
+		fmt.Fprintf(os.Stderr, "%s: request sent to %s, waiting up to %s\n", p.Test, p.Addr, p.Timeout)
+	case stunnat.PhaseConclusion:
+		fmt.Fprintf(os.Stderr, "%s: %s\n", p.Test, p.Conclusion)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %s from %s\n", p.Test, p.Phase, p.Addr)
+	}
+}
 
 func main() {
 	flag.Parse()
@@ -64,294 +68,116 @@ func main() {
 	case 3:
 		logLevel = logging.LogLevelTrace
 	}
-	log = logging.NewDefaultLeveledLoggerForScope("", logLevel, os.Stdout)
-
-	if err := mappingTests(*addrStrPtr); err != nil {
-		log.Warn("NAT mapping behavior: inconclusive")
-	}
-	if err := filteringTests(*addrStrPtr); err != nil {
-		log.Warn("NAT filtering behavior: inconclusive")
+	logOutput := os.Stdout
+	if *jsonOutput {
+		logOutput = os.Stderr
 	}
-}
+	log = logging.NewDefaultLeveledLoggerForScope("", logLevel, logOutput)
 
-// RFC5780: 4.3.  Determining NAT Mapping Behavior.
-func mappingTests(addrStr string) error { //nolint:cyclop
-	mapTestConn, err := connect(addrStr)
-	if err != nil {
-		log.Warnf("Error creating STUN connection: %s", err)
-
-		return err
+	cfg := stunnat.Config{
+		Server:  *addrStrPtr,
+		Timeout: time.Duration(*timeoutPtr) * time.Second,
+		Logger:  log,
 	}
-
-	// Test I: Regular binding request
-	log.Info("Mapping Test I: Regular binding request")
-	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
-
-	resp, err := mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
-	if err != nil {
-		return err
-	}
-
-	// Parse response message for XOR-MAPPED-ADDRESS and make sure OTHER-ADDRESS valid
-	resps1 := parse(resp)
-	if resps1.xorAddr == nil || resps1.otherAddr == nil {
-		log.Info("Error: NAT discovery feature not supported by this server")
-
-		return errNoOtherAddress
-	}
-	addr, err := net.ResolveUDPAddr("udp4", resps1.otherAddr.String())
-	if err != nil {
-		log.Infof("Failed resolving OTHER-ADDRESS: %v", resps1.otherAddr)
-
-		return err
+	if *showProgress {
+		cfg.Observer = printProgress
 	}
-	mapTestConn.OtherAddr = addr
-	log.Infof("Received XOR-MAPPED-ADDRESS: %v", resps1.xorAddr)
-
-	// Assert mapping behavior
-	if resps1.xorAddr.String() == mapTestConn.LocalAddr.String() {
-		log.Warn("=> NAT mapping behavior: endpoint independent (no NAT)")
-
-		return nil
+	res := discover(cfg)
+	printResult(res)
+	if *watchInterval <= 0 {
+		return
 	}
 
-	// Test II: Send binding request to the other address but primary port
-	log.Info("Mapping Test II: Send binding request to the other address but primary port")
-	oaddr := *mapTestConn.OtherAddr
-	oaddr.Port = mapTestConn.RemoteAddr.Port
-	resp, err = mapTestConn.roundTrip(request, &oaddr)
-	if err != nil {
-		return err
+	// Watch mode, e.g. for diagnosing carrier-grade NATs that change
+	// behavior or public address over time.
+	ticker := time.NewTicker(*watchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		next := discover(cfg)
+		logChanges(res, next)
+		printResult(next)
+		res = next
 	}
+}
 
-	// Assert mapping behavior
-	resps2 := parse(resp)
-	log.Infof("Received XOR-MAPPED-ADDRESS: %v", resps2.xorAddr)
-	if resps2.xorAddr.String() == resps1.xorAddr.String() {
-		log.Warn("=> NAT mapping behavior: endpoint independent")
-
-		return nil
+// discover runs mapping and filtering tests configured by cfg.
+func discover(cfg stunnat.Config) result {
+	res := result{
+		Mapping:   stunnat.BehaviorInconclusive,
+		Filtering: stunnat.BehaviorInconclusive,
 	}
-
-	// Test III: Send binding request to the other address and port
-	log.Info("Mapping Test III: Send binding request to the other address and port")
-	resp, err = mapTestConn.roundTrip(request, mapTestConn.OtherAddr)
-	if err != nil {
-		return err
+	if mapping, xorAddr, err := cfg.Mapping(); err != nil {
+		log.Warn("NAT mapping behavior: inconclusive")
+	} else {
+		res.Mapping, res.PublicIP = mapping, xorAddr.IP.String()
 	}
-
-	// Assert mapping behavior
-	resps3 := parse(resp)
-	log.Infof("Received XOR-MAPPED-ADDRESS: %v", resps3.xorAddr)
-	if resps3.xorAddr.String() == resps2.xorAddr.String() {
-		log.Warn("=> NAT mapping behavior: address dependent")
+	if filtering, err := cfg.Filtering(); err != nil {
+		log.Warn("NAT filtering behavior: inconclusive")
 	} else {
-		log.Warn("=> NAT mapping behavior: address and port dependent")
+		res.Filtering = filtering
 	}
+	res.Recommendation = recommend(res.Mapping, res.Filtering)
+	log.Warnf("=> ICE recommendation: %s", res.Recommendation)
 
-	return mapTestConn.Close()
+	return res
 }
 
-// RFC5780: 4.4.  Determining NAT Filtering Behavior.
-func filteringTests(addrStr string) error { //nolint:cyclop
-	mapTestConn, err := connect(addrStr)
-	if err != nil {
-		log.Warnf("Error creating STUN connection: %s", err)
-
-		return err
-	}
-
-	// Test I: Regular binding request
-	log.Info("Filtering Test I: Regular binding request")
-	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
-
-	resp, err := mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
-	if err != nil || errors.Is(err, errTimedOut) {
-		return err
+// printResult prints res as JSON line to stdout if JSON output is enabled.
+func printResult(res result) {
+	if !*jsonOutput {
+		return
 	}
-	resps := parse(resp)
-	if resps.xorAddr == nil || resps.otherAddr == nil {
-		log.Warn("Error: NAT discovery feature not supported by this server")
-
-		return errNoOtherAddress
-	}
-	addr, err := net.ResolveUDPAddr("udp4", resps.otherAddr.String())
-	if err != nil {
-		log.Infof("Failed resolving OTHER-ADDRESS: %v", resps.otherAddr)
-
-		return err
-	}
-	mapTestConn.OtherAddr = addr
-
-	// Test II: Request to change both IP and port
-	log.Info("Filtering Test II: Request to change both IP and port")
-	request = stun.MustBuild(stun.TransactionID, stun.BindingRequest)
-	request.Add(stun.AttrChangeRequest, []byte{0x00, 0x00, 0x00, 0x06})
-
-	resp, err = mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
-	if err == nil {
-		parse(resp) // just to print out the resp
-		log.Warn("=> NAT filtering behavior: endpoint independent")
-
-		return nil
-	} else if !errors.Is(err, errTimedOut) {
-		return err // something else went wrong
-	}
-
-	// Test III: Request to change port only
-	log.Info("Filtering Test III: Request to change port only")
-	request = stun.MustBuild(stun.TransactionID, stun.BindingRequest)
-	request.Add(stun.AttrChangeRequest, []byte{0x00, 0x00, 0x00, 0x02})
-
-	resp, err = mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
-	if err == nil {
-		parse(resp) // just to print out the resp
-		log.Warn("=> NAT filtering behavior: address dependent")
-	} else if errors.Is(err, errTimedOut) {
-		log.Warn("=> NAT filtering behavior: address and port dependent")
+	if err := json.NewEncoder(os.Stdout).Encode(res); err != nil {
+		log.Errorf("Failed to encode result: %v", err)
+		os.Exit(1)
 	}
-
-	return mapTestConn.Close()
 }
 
-// Parse a STUN message.
-func parse(msg *stun.Message) (ret struct {
-	xorAddr    *stun.XORMappedAddress
-	otherAddr  *stun.OtherAddress
-	respOrigin *stun.ResponseOrigin
-	mappedAddr *stun.MappedAddress
-	software   *stun.Software
-},
-) {
-	ret.mappedAddr = &stun.MappedAddress{}
-	ret.xorAddr = &stun.XORMappedAddress{}
-	ret.respOrigin = &stun.ResponseOrigin{}
-	ret.otherAddr = &stun.OtherAddress{}
-	ret.software = &stun.Software{}
-	if ret.xorAddr.GetFrom(msg) != nil {
-		ret.xorAddr = nil
-	}
-	if ret.otherAddr.GetFrom(msg) != nil {
-		ret.otherAddr = nil
-	}
-	if ret.respOrigin.GetFrom(msg) != nil {
-		ret.respOrigin = nil
-	}
-	if ret.mappedAddr.GetFrom(msg) != nil {
-		ret.mappedAddr = nil
-	}
-	if ret.software.GetFrom(msg) != nil {
-		ret.software = nil
-	}
-	log.Debugf("%v", msg)
-	log.Debugf("\tMAPPED-ADDRESS:     %v", ret.mappedAddr)
-	log.Debugf("\tXOR-MAPPED-ADDRESS: %v", ret.xorAddr)
-	log.Debugf("\tRESPONSE-ORIGIN:    %v", ret.respOrigin)
-	log.Debugf("\tOTHER-ADDRESS:      %v", ret.otherAddr)
-	log.Debugf("\tSOFTWARE: %v", ret.software)
-	for _, attr := range msg.Attributes {
-		switch attr.Type {
-		case
-			stun.AttrXORMappedAddress,
-			stun.AttrOtherAddress,
-			stun.AttrResponseOrigin,
-			stun.AttrMappedAddress,
-			stun.AttrSoftware:
-			break //nolint:staticcheck
-		default:
-			log.Debugf("\t%v (l=%v)", attr, attr.Length)
+// logChanges logs differences between consecutive results in watch mode.
+// Public port is not compared, as each run uses new local port.
+func logChanges(prev, next result) {
+	unknown := func(s string) string {
+		if s == "" {
+			return "unknown"
 		}
-	}
-
-	return ret
-}
-
-// Given an address string, returns a StunServerConn.
-func connect(addrStr string) (*stunServerConn, error) {
-	log.Infof("Connecting to STUN server: %s", addrStr)
-	addr, err := net.ResolveUDPAddr("udp4", addrStr)
-	if err != nil {
-		log.Warnf("Error resolving address: %s", err)
 
-		return nil, err
+		return s
 	}
-
-	c, err := net.ListenUDP("udp4", nil)
-	if err != nil {
-		return nil, err
+	if prev.PublicIP != next.PublicIP {
+		log.Warnf("Public IP changed: %s -> %s", unknown(prev.PublicIP), unknown(next.PublicIP))
 	}
-	log.Infof("Local address: %s", c.LocalAddr())
-	log.Infof("Remote address: %s", addr.String())
-
-	mChan := listen(c)
-
-	return &stunServerConn{
-		conn:        c,
-		LocalAddr:   c.LocalAddr(),
-		RemoteAddr:  addr,
-		messageChan: mChan,
-	}, nil
-}
-
-// Send request and wait for response or timeout.
-func (c *stunServerConn) roundTrip(msg *stun.Message, addr net.Addr) (*stun.Message, error) {
-	_ = msg.NewTransactionID()
-	log.Infof("Sending to %v: (%v bytes)", addr, msg.Length+messageHeaderSize)
-	log.Debugf("%v", msg)
-	for _, attr := range msg.Attributes {
-		log.Debugf("\t%v (l=%v)", attr, attr.Length)
+	if prev.Mapping != next.Mapping {
+		log.Warnf("NAT mapping behavior changed: %s -> %s", prev.Mapping, next.Mapping)
 	}
-	_, err := c.conn.WriteTo(msg.Raw, addr)
-	if err != nil {
-		log.Warnf("Error sending request to %v", addr)
-
-		return nil, err
-	}
-
-	// Wait for response or timeout
-	select {
-	case m, ok := <-c.messageChan:
-		if !ok {
-			return nil, errResponseMessage
-		}
-
-		return m, nil
-	case <-time.After(time.Duration(*timeoutPtr) * time.Second):
-		log.Infof("Timed out waiting for response from server %v", addr)
-
-		return nil, errTimedOut
+	if prev.Filtering != next.Filtering {
+		log.Warnf("NAT filtering behavior changed: %s -> %s", prev.Filtering, next.Filtering)
 	}
 }
 
-// taken from https://github.com/pion/stun/blob/master/cmd/stun-traversal/main.go
-func listen(conn *net.UDPConn) (messages chan *stun.Message) {
-	messages = make(chan *stun.Message)
-	go func() {
-		for {
-			buf := make([]byte, 1024)
-
-			n, addr, err := conn.ReadFromUDP(buf)
-			if err != nil {
-				close(messages)
-
-				return
-			}
-			log.Infof("Response from %v: (%v bytes)", addr, n)
-			buf = buf[:n]
-
-			m := new(stun.Message)
-			m.Raw = buf
-			err = m.Decode()
-			if err != nil {
-				log.Infof("Error decoding message: %v", err)
-				close(messages)
-
-				return
-			}
-
-			messages <- m
-		}
-	}()
-
-	return
+// recommend returns ICE configuration guidance for given NAT mapping and
+// filtering behaviors, see RFC 4787 and RFC 8445 Section 2.
+func recommend(mapping, filtering stunnat.Behavior) string {
+	switch {
+	case mapping == stunnat.BehaviorNoNAT:
+		return "no NAT: host candidates are sufficient, STUN and TURN are optional"
+	case mapping == stunnat.BehaviorInconclusive || filtering == stunnat.BehaviorInconclusive:
+		return "NAT behavior is unknown: configure both STUN and TURN servers"
+	case mapping == stunnat.BehaviorEndpointIndependent && filtering == stunnat.BehaviorEndpointIndependent:
+		return "server reflexive candidates are reachable by any peer: STUN is sufficient"
+	case mapping == stunnat.BehaviorEndpointIndependent:
+		return fmt.Sprintf(
+			"%s filtering opens only after outgoing checks: STUN is sufficient with ICE, TURN only for peers behind symmetric NAT",
+			filtering,
+		)
+	case filtering == stunnat.BehaviorAddressPortDependent:
+		return fmt.Sprintf(
+			"%s mapping with %s filtering (symmetric NAT): TURN relay is required",
+			mapping, filtering,
+		)
+	default:
+		return fmt.Sprintf(
+			"%s mapping: server reflexive candidates are unusable, peer reflexive may work, configure TURN as fallback",
+			mapping,
+		)
+	}
 }
@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package main implements a simple CLI tool to perform NAT traversal over TCP
+// via STUN and TCP simultaneous open
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+var server = flag.String("server", "stun.voipgate.com:3478", "Stun server address") //nolint:gochecknoglobals
+
+const (
+	tcp           = "tcp4"
+	pingMsg       = "ping"
+	pongMsg       = "pong"
+	timeoutMillis = 500
+	attempts      = 20
+)
+
+var errNoMappedAddress = errors.New("no XOR-MAPPED-ADDRESS in response")
+
+func main() {
+	flag.Parse()
+
+	// All connections share the same local port, so the NAT mapping
+	// discovered via STUN server is used to reach the peer.
+	listener, err := listenConfig().Listen(context.Background(), tcp, ":0")
+	if err != nil {
+		log.Fatalf("Failed to listen: %s", err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	localAddr, _ := listener.Addr().(*net.TCPAddr)
+	log.Printf("Listening on %s", localAddr)
+
+	publicAddr, client, err := mappedAddress(localAddr)
+	if err != nil {
+		log.Fatalf("Failed to get public address: %s", err)
+	}
+	log.Printf("My public address: %s", publicAddr)
+
+	log.Println("Enter remote peer address:")
+	peerStr, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	peerAddr, err := net.ResolveTCPAddr(tcp, strings.Trim(peerStr, " \r\n"))
+	if err != nil {
+		log.Fatalf("Failed to resolve peer addr: %s", err)
+	}
+
+	conn, err := connect(listener, localAddr, peerAddr)
+	if err != nil {
+		log.Fatalf("Failed to connect to peer: %s", err)
+	}
+	// Connection to STUN server keeps NAT mapping of the shared local port
+	// alive until peer is reached, as some NATs drop TCP mapping on FIN.
+	if err = client.Close(); err != nil {
+		log.Printf("Failed to close STUN client: %s", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	log.Printf("Connected %s -> %s", conn.LocalAddr(), conn.RemoteAddr())
+
+	if err = pingPong(conn); err != nil {
+		log.Fatalf("Failed to exchange messages: %s", err)
+	}
+	log.Println("Success!")
+}
+
+// mappedAddress returns public address of localAddr, performing binding
+// request to STUN server over TCP. The returned client should be closed
+// only after connection with peer is established, keeping the mapping.
+func mappedAddress(localAddr *net.TCPAddr) (stun.XORMappedAddress, *stun.Client, error) {
+	var addr stun.XORMappedAddress
+	dialer := &net.Dialer{
+		LocalAddr: localAddr,
+		Control:   reuseControl,
+		Timeout:   timeoutMillis * time.Millisecond * attempts,
+	}
+	conn, err := dialer.Dial(tcp, *server)
+	if err != nil {
+		return addr, nil, fmt.Errorf("dial server: %w", err)
+	}
+	client, err := stun.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+
+		return addr, nil, err
+	}
+	var resErr error
+	if err = client.Do(stun.MustBuild(stun.TransactionID, stun.BindingRequest), func(e stun.Event) {
+		if e.Error != nil {
+			resErr = e.Error
+
+			return
+		}
+		xorAddr, getErr := stun.Parse[stun.XORMappedAddress](e.Message)
+		if getErr != nil {
+			resErr = fmt.Errorf("%w: %s", errNoMappedAddress, getErr)
+
+			return
+		}
+		addr = *xorAddr
+	}); err != nil {
+		resErr = err
+	}
+	if resErr != nil {
+		_ = client.Close()
+
+		return addr, nil, resErr
+	}
+
+	return addr, client, nil
+}
+
+// connect establishes TCP connection with peer, accepting on listener and
+// repeatedly dialing from the same local address, so connection is
+// established either by simultaneous open or by peer SYN passing our NAT.
+func connect(listener net.Listener, localAddr, peerAddr *net.TCPAddr) (net.Conn, error) {
+	conns := make(chan net.Conn, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conns <- conn
+		}
+	}()
+	dialer := &net.Dialer{
+		LocalAddr: localAddr,
+		Control:   reuseControl,
+		Timeout:   timeoutMillis * time.Millisecond,
+	}
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		select {
+		case conn := <-conns:
+			return conn, nil
+		default:
+		}
+		conn, err := dialer.Dial(tcp, peerAddr.String())
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		log.Printf("Attempt %d: %s", i+1, err)
+		time.Sleep(timeoutMillis * time.Millisecond)
+	}
+	select {
+	case conn := <-conns:
+		return conn, nil
+	default:
+		return nil, lastErr
+	}
+}
+
+// pingPong sends ping to peer and waits for pong, answering peer ping.
+func pingPong(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(timeoutMillis * time.Millisecond * attempts)); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(conn, pingMsg); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch strings.TrimSpace(line) {
+		case pingMsg:
+			if _, err = fmt.Fprintln(conn, pongMsg); err != nil {
+				return err
+			}
+		case pongMsg:
+			log.Println("Received pong message.")
+
+			return nil
+		default:
+			log.Printf("Unknown message %q", line)
+		}
+	}
+}
+
+// listenConfig returns config for listener that shares local port with
+// dialed connections.
+func listenConfig() *net.ListenConfig {
+	return &net.ListenConfig{Control: reuseControl}
+}
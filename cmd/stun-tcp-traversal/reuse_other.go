@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errReuseNotSupported = errors.New("port reuse is not supported on this platform")
+
+func reuseControl(string, string, syscall.RawConn) error {
+	return errReuseNotSupported
+}
@@ -90,8 +90,8 @@ func main() { //nolint:gocognit,cyclop
 
 					break
 				}
-				var xorAddr stun.XORMappedAddress
-				if getErr := xorAddr.GetFrom(m); getErr != nil {
+				xorAddr, getErr := stun.Parse[stun.XORMappedAddress](m)
+				if getErr != nil {
 					log.Println("getFrom:", getErr)
 
 					break
@@ -99,7 +99,7 @@ func main() { //nolint:gocognit,cyclop
 
 				if publicAddr.String() != xorAddr.String() {
 					log.Printf("My public address: %s\n", xorAddr)
-					publicAddr = xorAddr
+					publicAddr = *xorAddr
 
 					peerAddrChan = getPeerAddr()
 				}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "fmt"
+
+// ConnectionID represents CONNECTION-ID attribute, which uniquely identifies
+// peer data connection in TURN-TCP.
+//
+// RFC 6062 Section 6.2.1.
+type ConnectionID uint32
+
+const connectionIDSize = 4 // 32 bit
+
+func (c ConnectionID) String() string {
+	return fmt.Sprintf("0x%x", uint32(c))
+}
+
+// AddTo adds CONNECTION-ID attribute to message.
+func (c ConnectionID) AddTo(m *Message) error {
+	v := make([]byte, connectionIDSize)
+	bin.PutUint32(v, uint32(c))
+	m.Add(AttrConnectionID, v)
+
+	return nil
+}
+
+// GetFrom decodes CONNECTION-ID attribute from message.
+func (c *ConnectionID) GetFrom(m *Message) error {
+	v, err := m.Get(AttrConnectionID)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrConnectionID, len(v), connectionIDSize); err != nil {
+		return err
+	}
+	*c = ConnectionID(bin.Uint32(v))
+
+	return nil
+}
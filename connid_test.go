@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConnectionID(t *testing.T) {
+	m := new(Message)
+	m.Type = NewType(MethodConnectionBind, ClassRequest)
+	id := ConnectionID(0xdeadbeef)
+	if err := m.Build(m.Type, TransactionID, id); err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(Message)
+	if _, err := decoded.Write(m.Raw); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Type.Method != MethodConnectionBind {
+		t.Errorf("unexpected method %s", decoded.Type.Method)
+	}
+	var got ConnectionID
+	if err := got.GetFrom(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got != id {
+		t.Errorf("got %s, expected %s", got, id)
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrConnectionID, []byte{1, 2, 3})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		if err := got.GetFrom(new(Message)); !errors.Is(err, ErrAttributeNotFound) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
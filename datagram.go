@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"context"
+	"net"
+)
+
+// DatagramConn is a message-oriented connection that carries unreliable
+// datagrams, e.g. QUIC connection with DATAGRAM frames (RFC 9221). The
+// method set matches quic-go Connection, so it can be used directly.
+type DatagramConn interface {
+	SendDatagram(b []byte) error
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// NewDatagramConnection returns Connection that sends each message as a
+// single datagram over conn, allowing to run Client over QUIC DATAGRAM
+// frames. Experimental, the API can change.
+//
+// Closing returned Connection unblocks pending Read, but does not close
+// conn, as it is usually shared with other streams. For reliable transport
+// QUIC stream can be passed to NewClient with WithStreamFraming, like TCP
+// connection.
+func NewDatagramConnection(conn DatagramConn) Connection {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &datagramConnection{
+		conn:   conn,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+type datagramConnection struct {
+	conn   DatagramConn
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+}
+
+// Read reads next datagram into b, truncating it if b is too small.
+func (c *datagramConnection) Read(b []byte) (int, error) {
+	d, err := c.conn.ReceiveDatagram(c.ctx)
+	if err != nil {
+		if c.ctx.Err() != nil {
+			return 0, net.ErrClosed
+		}
+
+		return 0, err
+	}
+
+	return copy(b, d), nil
+}
+
+func (c *datagramConnection) Write(b []byte) (int, error) {
+	if c.ctx.Err() != nil {
+		return 0, net.ErrClosed
+	}
+	if err := c.conn.SendDatagram(b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (c *datagramConnection) Close() error {
+	c.cancel()
+
+	return nil
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// chanDatagramConn is DatagramConn over channels.
+type chanDatagramConn struct {
+	in  <-chan []byte
+	out chan<- []byte
+}
+
+func (c chanDatagramConn) SendDatagram(b []byte) error {
+	c.out <- append([]byte(nil), b...)
+
+	return nil
+}
+
+func (c chanDatagramConn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-c.in:
+		return b, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestDatagramConnection(t *testing.T) {
+	var (
+		toServer = make(chan []byte, 1)
+		toClient = make(chan []byte, 1)
+		conn     = NewDatagramConnection(chanDatagramConn{in: toClient, out: toServer})
+	)
+	go func() {
+		req := new(Message)
+		for b := range toServer {
+			if err := Decode(b, req); err != nil {
+				t.Error(err)
+
+				return
+			}
+			toClient <- MustBuild(req, BindingSuccess).Raw
+		}
+	}()
+	defer close(toServer)
+	client, err := NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = client.DoContext(ctx, MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Read(make([]byte, 10)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected read error %v", err)
+	}
+	if _, err = conn.Write([]byte{1}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected write error %v", err)
+	}
+}
@@ -14,8 +14,13 @@ import (
 	"github.com/pion/stun/v3"
 )
 
+const (
+	authServer      = "stun-server"
+	anonymousServer = "stun-server-anonymous"
+)
+
 func test(network string) { //nolint:cyclop
-	addr := resolve(network)
+	addr := resolve(authServer, network)
 	fmt.Println("START", strings.ToUpper(addr.Network())) //nolint
 	var (
 		nonce stun.Nonce
@@ -52,8 +57,11 @@ func test(network string) { //nolint:cyclop
 		if response.Type != stun.BindingError {
 			log.Fatalln("bad message", response) //nolint
 		}
-		var errCode stun.ErrorCodeAttribute
-		if codeErr := errCode.GetFrom(response); codeErr != nil {
+		if fpErr := stun.Fingerprint.Check(response); fpErr != nil {
+			log.Fatalln("failed to check fingerprint:", fpErr) //nolint
+		}
+		errCode, codeErr := stun.Parse[stun.ErrorCodeAttribute](response)
+		if codeErr != nil {
 			log.Fatalln("failed to get error code:", codeErr) //nolint
 		}
 		if errCode.Code != stun.CodeUnauthorized {
@@ -68,9 +76,10 @@ func test(network string) { //nolint:cyclop
 	}
 
 	// Authenticating and sending second request.
+	integrity := stun.NewLongTermIntegrity(username, realm.String(), password)
 	request, err = stun.Build(stun.TransactionID, stun.BindingRequest,
 		stun.NewUsername(username), nonce, realm,
-		stun.NewLongTermIntegrity(username, realm.String(), password),
+		integrity,
 		stun.Fingerprint,
 	)
 	if err != nil {
@@ -82,12 +91,15 @@ func test(network string) { //nolint:cyclop
 		}
 		response := event.Message
 		if response.Type != stun.BindingSuccess {
-			var errCode stun.ErrorCodeAttribute
-			if codeErr := errCode.GetFrom(response); codeErr != nil {
+			errCode, codeErr := stun.Parse[stun.ErrorCodeAttribute](response)
+			if codeErr != nil {
 				log.Fatalln("failed to get error code:", codeErr) //nolint
 			}
 			log.Fatalln("bad message", response, errCode) //nolint
 		}
+		if checkErr := response.Check(integrity, stun.Fingerprint); checkErr != nil {
+			log.Fatalln("failed to check response:", checkErr) //nolint
+		}
 		var xorMapped stun.XORMappedAddress
 		if err = response.Parse(&xorMapped); err != nil {
 			log.Fatalln("failed to parse xor mapped address:", err) //nolint
@@ -105,8 +117,62 @@ func test(network string) { //nolint:cyclop
 	fmt.Println("OK", strings.ToUpper(addr.Network())) //nolint
 }
 
-func resolve(network string) net.Addr {
-	addr := fmt.Sprintf("%s:%d", "stun-server", stun.DefaultPort)
+// testAnonymous checks binding without credentials, with and
+// without FINGERPRINT in request.
+func testAnonymous(network string) {
+	addr := resolve(anonymousServer, network)
+	fmt.Println("START ANONYMOUS", strings.ToUpper(addr.Network())) //nolint
+	conn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		log.Fatalln("failed to dial conn:", err) //nolint
+	}
+	var options []stun.ClientOption
+	if network == "tcp" {
+		options = append(options, stun.WithNoRetransmit)
+	}
+	client, err := stun.NewClient(conn, options...)
+	if err != nil {
+		log.Fatal(err) //nolint
+	}
+	for _, setters := range [][]stun.Setter{
+		{stun.TransactionID, stun.BindingRequest},
+		{stun.TransactionID, stun.BindingRequest, stun.Fingerprint},
+	} {
+		request, buildErr := stun.Build(setters...)
+		if buildErr != nil {
+			log.Fatalln("failed to build:", buildErr) //nolint
+		}
+		if err = client.Do(request, func(event stun.Event) {
+			if event.Error != nil {
+				log.Fatalln("got event with error:", event.Error) //nolint
+			}
+			response := event.Message
+			if response.Type != stun.BindingSuccess {
+				log.Fatalln("bad message", response) //nolint
+			}
+			if fpErr := stun.Fingerprint.Check(response); fpErr != nil {
+				log.Fatalln("failed to check fingerprint:", fpErr) //nolint
+			}
+			var xorMapped stun.XORMappedAddress
+			if parseErr := response.Parse(&xorMapped); parseErr != nil {
+				log.Fatalln("failed to parse xor mapped address:", parseErr) //nolint
+			}
+			if conn.LocalAddr().String() != xorMapped.String() {
+				log.Fatalln(conn.LocalAddr(), "!=", xorMapped) //nolint
+			}
+			fmt.Println("OK", response, "GOT", xorMapped) //nolint
+		}); err != nil {
+			log.Fatalln("failed to Do:", err) //nolint
+		}
+	}
+	if err := client.Close(); err != nil {
+		log.Fatalln("failed to close client:", err) //nolint
+	}
+	fmt.Println("OK ANONYMOUS", strings.ToUpper(addr.Network())) //nolint
+}
+
+func resolve(host, network string) net.Addr {
+	addr := fmt.Sprintf("%s:%d", host, stun.DefaultPort)
 	var (
 		resolved   net.Addr
 		resolveErr error
@@ -129,6 +195,8 @@ func resolve(network string) net.Addr {
 }
 
 func main() {
+	testAnonymous("udp")
+	testAnonymous("tcp")
 	test("udp")
 	test("tcp")
 }
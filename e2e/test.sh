@@ -57,6 +57,7 @@ TEST_EXIT_CODE=`docker wait ci_stun-client_1`
 
 docker logs ci_stun-client_1 &> log-client.txt
 docker logs ci_stun-server_1 &> log-server.txt
+docker logs ci_stun-server-anonymous_1 &> log-server-anonymous.txt
 docker logs ci_stun-tcpdump &> log-tcpdump.txt
 
 # output the logs for the test (for clarity)
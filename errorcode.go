@@ -137,6 +137,19 @@ const (
 	CodePeerAddrFamilyMismatch ErrorCode = 443 // Peer Address Family Mismatch
 )
 
+// ErrorCodeReasons returns registry of known error codes and their
+// default reasons, e.g. "Unauthorized" for CodeUnauthorized.
+//
+// Returned map is a copy, so modifying it does not affect ErrorCode.AddTo.
+func ErrorCodeReasons() map[ErrorCode]string {
+	reasons := make(map[ErrorCode]string, len(errorReasons))
+	for code, reason := range errorReasons {
+		reasons[code] = string(reason)
+	}
+
+	return reasons
+}
+
 //nolint:gochecknoglobals
 var errorReasons = map[ErrorCode][]byte{
 	CodeTryAlternate:     []byte("Try Alternate"),
@@ -3,7 +3,11 @@
 
 package stun
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"net"
+)
 
 // DecodeErr records an error and place when it is occurred.
 //
@@ -64,3 +68,47 @@ var ErrAttributeSizeInvalid = errors.New("attribute size is invalid")
 
 // ErrAttributeSizeOverflow means that decoded attribute size is too big.
 var ErrAttributeSizeOverflow = errors.New("attribute size overflow")
+
+// netError is an error that implements net.Error, allowing callers to
+// classify it without enumerating sentinel errors.
+type netError struct {
+	msg       string
+	timeout   bool
+	temporary bool
+	err       error // wrapped error, if any
+}
+
+func (e *netError) Error() string { return e.msg }
+
+// Unwrap returns wrapped error, e.g. read error of connection.
+func (e *netError) Unwrap() error { return e.err }
+
+// Timeout implements net.Error.
+func (e *netError) Timeout() bool { return e.timeout }
+
+// Temporary implements net.Error.
+func (e *netError) Temporary() bool { return e.temporary }
+
+// Is makes timeout errors match context.DeadlineExceeded, so they can be
+// handled same way as expired context deadline.
+func (e *netError) Is(target error) bool {
+	return e.timeout && target == context.DeadlineExceeded //nolint:errorlint
+}
+
+// IsTimeout reports whether err (or any error it wraps) is a timeout,
+// e.g. ErrTransactionTimeOut or a connection deadline error.
+func IsTimeout(err error) bool {
+	var nErr net.Error
+
+	return errors.As(err, &nErr) && nErr.Timeout()
+}
+
+// IsNetworkError reports whether err (or any error it wraps) implements
+// net.Error, e.g. ErrTransactionTimeOut, ErrClientClosed or an error
+// returned by the underlying connection, including ones passed to
+// WithConnErrorHandler.
+func IsNetworkError(err error) bool {
+	var nErr net.Error
+
+	return errors.As(err, &nErr)
+}
@@ -4,7 +4,11 @@
 package stun
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
+	"os"
 	"testing"
 )
 
@@ -36,3 +40,60 @@ func TestDecodeErr_IsInvalidCookie(t *testing.T) {
 		t.Error("bad parent")
 	}
 }
+
+type testTimeoutErr struct{}
+
+func (testTimeoutErr) Error() string   { return "i/o timeout" }
+func (testTimeoutErr) Timeout() bool   { return true }
+func (testTimeoutErr) Temporary() bool { return true }
+
+func TestIsTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		err     error
+		timeout bool
+		network bool
+	}{
+		{"Nil", nil, false, false},
+		{"Plain", errors.New("plain"), false, false}, //nolint:err113
+		{"TransactionTimeOut", ErrTransactionTimeOut, true, true},
+		{"Wrapped", fmt.Errorf("failed: %w", ErrTransactionTimeOut), true, true},
+		{"Conn", testTimeoutErr{}, true, true},
+		{"StopErr", StopErr{Cause: testTimeoutErr{}, Err: ErrTransactionNotExists}, true, true},
+		{"CloseErr", CloseErr{ConnectionErr: &net.OpError{Op: "close", Err: ErrAgentClosed}}, false, true},
+		{"ClientClosed", ErrClientClosed, false, true},
+		{"NoConnection", ErrNoConnection, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsTimeout(tc.err); got != tc.timeout {
+				t.Errorf("IsTimeout(%v) = %v, expected %v", tc.err, got, tc.timeout)
+			}
+			if got := IsNetworkError(tc.err); got != tc.network {
+				t.Errorf("IsNetworkError(%v) = %v, expected %v", tc.err, got, tc.network)
+			}
+		})
+	}
+}
+
+func TestTimeoutConventions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		err     error
+		timeout bool
+	}{
+		{"TransactionTimeOut", ErrTransactionTimeOut, true},
+		{"StopErr", StopErr{Err: ErrTransactionNotExists, Cause: ErrTransactionTimeOut}, true},
+		{"StopErrNoTimeout", StopErr{Err: ErrTransactionNotExists, Cause: ErrAgentClosed}, false},
+		{"CloseErr", CloseErr{ConnectionErr: context.DeadlineExceeded}, true},
+		{"CloseErrNoTimeout", CloseErr{AgentErr: ErrAgentClosed}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := os.IsTimeout(tc.err); got != tc.timeout {
+				t.Errorf("os.IsTimeout(%v) = %v, expected %v", tc.err, got, tc.timeout)
+			}
+			if got := errors.Is(tc.err, context.DeadlineExceeded); got != tc.timeout {
+				t.Errorf("errors.Is(%v, context.DeadlineExceeded) = %v, expected %v", tc.err, got, tc.timeout)
+			}
+		})
+	}
+}
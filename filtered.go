@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"net"
+	"sync"
+)
+
+// filteredConnQueueSize is number of STUN messages buffered for each
+// Connection of FilteredConn, messages are dropped on overflow like in
+// UDP socket.
+const filteredConnQueueSize = 16
+
+// FilteredConn wraps net.PacketConn, absorbing STUN messages, so ReadFrom
+// returns only non-STUN packets. It is the inverse of demultiplexer for
+// applications that own the read loop: STUN messages are passed to
+// Connection of corresponding server (see Connection) or to handler.
+//
+// STUN messages are delivered only while ReadFrom is called, and are
+// truncated if buffer passed to ReadFrom is too small.
+type FilteredConn struct {
+	net.PacketConn
+	handler func(b []byte, addr net.Addr)
+	mux     sync.Mutex
+	conns   map[string]*filteredConnection
+}
+
+// NewFilteredConn returns FilteredConn over conn. STUN messages from
+// addresses without Connection are passed to h if it is not nil, b is
+// valid only during call.
+func NewFilteredConn(conn net.PacketConn, h func(b []byte, addr net.Addr)) *FilteredConn {
+	return &FilteredConn{
+		PacketConn: conn,
+		handler:    h,
+		conns:      make(map[string]*filteredConnection),
+	}
+}
+
+// ReadFrom reads next non-STUN packet into b, passing STUN messages read
+// before it to corresponding Connection or handler.
+func (c *FilteredConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil || !IsMessage(b[:n]) {
+			return n, addr, err
+		}
+		c.mux.Lock()
+		conn := c.conns[addr.String()]
+		c.mux.Unlock()
+		switch {
+		case conn != nil:
+			conn.deliver(b[:n])
+		case c.handler != nil:
+			c.handler(b[:n], addr)
+		}
+	}
+}
+
+// Connection returns Connection that writes to server address and reads
+// STUN messages from it, e.g. for NewClient. Closing returned Connection
+// does not close FilteredConn.
+func (c *FilteredConn) Connection(server net.Addr) Connection {
+	conn := &filteredConnection{
+		filtered: c,
+		server:   server,
+		messages: make(chan []byte, filteredConnQueueSize),
+		closed:   make(chan struct{}),
+	}
+	c.mux.Lock()
+	c.conns[server.String()] = conn
+	c.mux.Unlock()
+
+	return conn
+}
+
+type filteredConnection struct {
+	filtered  *FilteredConn
+	server    net.Addr
+	messages  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// deliver queues copy of b, dropping it if queue is full.
+func (c *filteredConnection) deliver(b []byte) {
+	select {
+	case c.messages <- append([]byte(nil), b...):
+	default:
+	}
+}
+
+// Read reads next STUN message from server into b, truncating it if b is
+// too small.
+func (c *filteredConnection) Read(b []byte) (int, error) {
+	select {
+	case m := <-c.messages:
+		return copy(b, m), nil
+	case <-c.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+func (c *filteredConnection) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	return c.filtered.WriteTo(b, c.server)
+}
+
+func (c *filteredConnection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.filtered.mux.Lock()
+		if c.filtered.conns[c.server.String()] == c {
+			delete(c.filtered.conns, c.server.String())
+		}
+		c.filtered.mux.Unlock()
+	})
+
+	return nil
+}
+
+func (c *filteredConnection) LocalAddr() net.Addr {
+	return c.filtered.LocalAddr()
+}
+
+func (c *filteredConnection) RemoteAddr() net.Addr {
+	return c.server
+}
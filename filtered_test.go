@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFilteredConn(t *testing.T) { //nolint:cyclop
+	listen := func() net.PacketConn {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return conn
+	}
+	server, peer, local := listen(), listen(), listen()
+	defer server.Close() //nolint:errcheck
+	defer peer.Close()   //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		req := new(Message)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if Decode(buf[:n], req) != nil {
+				continue
+			}
+			// Application data and response to the same address.
+			if _, err = server.WriteTo([]byte("data"), addr); err != nil {
+				t.Error(err)
+			}
+			if _, err = server.WriteTo(MustBuild(req, BindingSuccess).Raw, addr); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	fromPeer := make(chan net.Addr, 1)
+	filtered := NewFilteredConn(local, func(b []byte, addr net.Addr) {
+		if IsMessage(b) {
+			fromPeer <- addr
+		}
+	})
+	defer filtered.Close() //nolint:errcheck
+	appData := make(chan string, 10)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := filtered.ReadFrom(buf)
+			if err != nil {
+				close(appData)
+
+				return
+			}
+			appData <- string(buf[:n])
+		}
+	}()
+	conn := filtered.Connection(server.LocalAddr())
+	client, err := NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.RemoteAddr() != server.LocalAddr() || client.LocalAddr() != local.LocalAddr() {
+		t.Error("unexpected addresses")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = client.DoContext(ctx, MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-appData; data != "data" {
+		t.Errorf("unexpected application data %q", data)
+	}
+	if _, err = peer.WriteTo(MustBuild(TransactionID, BindingRequest).Raw, local.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case addr := <-fromPeer:
+		if addr.String() != peer.LocalAddr().String() {
+			t.Errorf("unexpected address %s", addr)
+		}
+	case <-time.After(time.Second):
+		t.Error("STUN message from peer is not handled")
+	}
+	if err = client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte{1}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected write error %v", err)
+	}
+	if len(filtered.conns) != 0 {
+		t.Error("connection should be removed on close")
+	}
+}
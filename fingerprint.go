@@ -35,6 +35,9 @@ const (
 // up to (but excluding) the FINGERPRINT attribute itself, XOR'ed with
 // the 32-bit value 0x5354554e (the XOR helps in cases where an
 // application packet is also using CRC-32 in it).
+//
+// The crc32.ChecksumIEEE uses hardware acceleration or slicing-by-8 table
+// if available, so there is no need for custom table.
 func FingerprintValue(b []byte) uint32 {
 	return crc32.ChecksumIEEE(b) ^ fingerprintXORValue // XOR
 }
@@ -45,11 +48,10 @@ func (FingerprintAttr) AddTo(m *Message) error {
 	// length in header should include size of fingerprint attribute
 	m.Length += fingerprintSize + attributeHeaderSize // increasing length
 	m.WriteLength()                                   // writing Length to Raw
-	b := make([]byte, fingerprintSize)
-	val := FingerprintValue(m.Raw)
-	bin.PutUint32(b, val)
+	var b [fingerprintSize]byte
+	bin.PutUint32(b[:], FingerprintValue(m.Raw))
 	m.Length = l
-	m.Add(AttrFingerprint, b)
+	m.Add(AttrFingerprint, b[:])
 
 	return nil
 }
@@ -7,8 +7,11 @@
 package stun
 
 import (
+	"fmt"
 	"net"
 	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 func BenchmarkFingerprint_AddTo(b *testing.B) {
@@ -45,6 +48,25 @@ func TestFingerprint_Check(t *testing.T) {
 	}
 }
 
+func TestFingerprint_AddToZeroAlloc(t *testing.T) {
+	m := new(Message)
+	addAttr(t, m, NewSoftware("software"))
+	m.WriteHeader()
+	raw := append([]byte(nil), m.Raw...)
+	m.Raw = make([]byte, len(raw), len(raw)+attributeHeaderSize+fingerprintSize)
+	testutil.ShouldNotAllocate(t, func() {
+		m.Raw = append(m.Raw[:0], raw...)
+		m.Length = uint32(len(raw) - messageHeaderSize) //nolint:gosec // G115
+		m.Attributes = m.Attributes[:1]
+		if err := Fingerprint.AddTo(m); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := Fingerprint.Check(m); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestFingerprint_CheckBad(t *testing.T) {
 	m := new(Message)
 	addAttr(t, m, NewSoftware("software"))
@@ -77,3 +99,16 @@ func BenchmarkFingerprint_Check(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkFingerprintValue(b *testing.B) {
+	for _, size := range []int{64, 256, 1200} {
+		buf := make([]byte, size)
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				FingerprintValue(buf)
+			}
+		})
+	}
+}
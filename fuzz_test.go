@@ -6,10 +6,17 @@ package stun
 import (
 	"encoding/binary"
 	"errors"
+	"net"
+	"strings"
 	"testing"
 )
 
 func FuzzMessage(f *testing.F) {
+	// Seeding with real WebRTC traffic.
+	for _, data := range loadBrowserMessages(f) {
+		f.Add(data)
+	}
+	f.Add(loadData(f, "ex1_chrome.stun"))
 	msg1 := New()
 
 	f.Fuzz(func(t *testing.T, data []byte) {
@@ -65,18 +72,6 @@ func FuzzType(f *testing.F) {
 
 func FuzzSetters(f *testing.F) {
 	f.Fuzz(func(t *testing.T, firstByte byte, value []byte) {
-		var (
-			m1 = &Message{
-				Raw: make([]byte, 0, 2048),
-			}
-			m2 = &Message{
-				Raw: make([]byte, 0, 2048),
-			}
-			m3 = &Message{
-				Raw: make([]byte, 0, 2048),
-			}
-		)
-
 		attrs := attributes{
 			{new(Realm), AttrRealm},
 			{new(XORMappedAddress), AttrXORMappedAddress},
@@ -90,42 +85,197 @@ func FuzzSetters(f *testing.F) {
 			{new(Realm), AttrRealm},
 		}
 		attr := attrs.pick(firstByte)
+		fuzzAttribute(t, attr.g, attr.t, value)
+	})
+}
 
-		m1.WriteHeader()
-		m1.Add(attr.t, value)
-		err := attr.g.GetFrom(m1)
-		if errors.Is(err, ErrAttributeNotFound) {
-			t.Fatalf("Unexpected 404: %s", err)
+// fuzzAttribute decodes attribute g of type attrType from value and checks
+// that encoding it back results in the same message, returning true if
+// value was decoded.
+func fuzzAttribute(t *testing.T, g attr, attrType AttrType, value []byte) bool {
+	t.Helper()
+	var (
+		m1 = &Message{
+			Raw: make([]byte, 0, 2048),
 		}
-		if err != nil {
-			return
+		m2 = &Message{
+			Raw: make([]byte, 0, 2048),
 		}
+		m3 = &Message{
+			Raw: make([]byte, 0, 2048),
+		}
+	)
 
-		m2.WriteHeader()
-		if err = attr.g.AddTo(m2); err != nil {
-			// We allow decoding some text attributes
-			// when their length is too big, but
-			// not encoding.
-			if !IsAttrSizeOverflow(err) {
-				t.Fatal(err)
-			}
+	m1.WriteHeader()
+	m1.Add(attrType, value)
+	err := g.GetFrom(m1)
+	if errors.Is(err, ErrAttributeNotFound) {
+		t.Fatalf("Unexpected 404: %s", err)
+	}
+	if err != nil {
+		return false
+	}
 
-			return
+	m2.WriteHeader()
+	if err = g.AddTo(m2); err != nil {
+		// We allow decoding some text attributes
+		// when their length is too big or they are
+		// not valid UTF-8, but not encoding.
+		if !IsAttrSizeOverflow(err) && !errors.Is(err, ErrTextSyntax) {
+			t.Fatal(err)
 		}
 
-		m3.WriteHeader()
-		v, err := m2.Get(attr.t)
-		if err != nil {
-			t.Fatal(err)
+		return true
+	}
+
+	m3.WriteHeader()
+	v, err := m2.Get(attrType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m3.Add(attrType, v)
+
+	if !m2.Equal(m3) {
+		t.Fatalf("Not equal: %s != %s", m2, m3)
+	}
+
+	return true
+}
+
+// addressSeeds returns seed corpus for address attributes,
+// covering address family edge cases.
+func addressSeeds() [][]byte {
+	return [][]byte{
+		{0x00, 0x01, 0x0d, 0x96, 127, 0, 0, 1},             // IPv4
+		{0x00, 0x02, 0x0d, 0x96, 0xfe, 0x80, 15: 1, 19: 1}, // IPv6
+		{0x00, 0x01, 0x0d, 0x96, 0xfe, 0x80, 15: 1, 19: 1}, // IPv4 family, IPv6 length
+		{0x00, 0x02, 0x0d, 0x96, 127, 0, 0, 1},             // IPv6 family, IPv4 length
+		{0x00, 0x03, 0x0d, 0x96, 127, 0, 0, 1},             // unknown family
+		{0x00, 0x01, 0x0d, 0x96, 127},                      // truncated address
+		{0x00, 0x01, 0x0d, 0x96},                           // no address
+		{},
+	}
+}
+
+func checkFuzzedIP(t *testing.T, ip net.IP) {
+	t.Helper()
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		t.Fatalf("Unexpected IP length %d", len(ip))
+	}
+}
+
+func FuzzMappedAddress(f *testing.F) {
+	for _, seed := range addressSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		addr := new(MappedAddress)
+		if fuzzAttribute(t, addr, AttrMappedAddress, value) {
+			checkFuzzedIP(t, addr.IP)
 		}
-		m3.Add(attr.t, v)
+	})
+}
+
+func FuzzXORMappedAddress(f *testing.F) {
+	for _, seed := range addressSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		addr := new(XORMappedAddress)
+		if fuzzAttribute(t, addr, AttrXORMappedAddress, value) {
+			checkFuzzedIP(t, addr.IP)
+		}
+	})
+}
 
-		if !m2.Equal(m3) {
-			t.Fatalf("Not equal: %s != %s", m2, m3)
+func FuzzAlternateServer(f *testing.F) {
+	for _, seed := range addressSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		addr := new(AlternateServer)
+		if fuzzAttribute(t, addr, AttrAlternateServer, value) {
+			checkFuzzedIP(t, addr.IP)
 		}
 	})
 }
 
+func FuzzErrorCode(f *testing.F) {
+	f.Add([]byte{0, 0, 4, 1, 'U', 'n', 'a', 'u', 't', 'h'}) // 401
+	f.Add([]byte{0, 0, 3, 0})                               // 300, no reason
+	f.Add([]byte{0, 0, 6, 99})                              // 699, upper boundary
+	f.Add([]byte{0, 0, 0xff, 0xff})                         // invalid class and number
+	f.Add([]byte{0, 0, 4})                                  // truncated
+	f.Add(append([]byte{0, 0, 5, 0}, make([]byte, errorCodeReasonMaxB)...))
+	f.Add(append([]byte{0, 0, 5, 0}, make([]byte, errorCodeReasonMaxB+1)...))
+	f.Fuzz(func(t *testing.T, value []byte) {
+		attr := new(ErrorCodeAttribute)
+		if !fuzzAttribute(t, attr, AttrErrorCode, value) {
+			return
+		}
+		if attr.Code < 0 || attr.Code > 0xff*errorCodeModulo+0xff {
+			t.Fatalf("Unexpected code %d", attr.Code)
+		}
+	})
+}
+
+func FuzzUnknownAttributes(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0x00, 0x01, 0x80, 0x22})
+	f.Add([]byte{0x00, 0x01, 0x80})
+	f.Fuzz(func(t *testing.T, value []byte) {
+		fuzzAttribute(t, new(UnknownAttributes), AttrUnknownAttributes, value)
+	})
+}
+
+// textSeeds returns seed corpus for text attributes with maximum
+// length maxB, covering truncation edge cases.
+func textSeeds(maxB int) [][]byte {
+	return [][]byte{
+		{},
+		[]byte("value"),
+		[]byte("\xe2\x82"), // truncated UTF-8 sequence
+		[]byte(strings.Repeat("a", maxB)),
+		[]byte(strings.Repeat("a", maxB+1)),
+	}
+}
+
+func FuzzUsername(f *testing.F) {
+	for _, seed := range textSeeds(maxUsernameB) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		fuzzAttribute(t, new(Username), AttrUsername, value)
+	})
+}
+
+func FuzzRealm(f *testing.F) {
+	for _, seed := range textSeeds(maxRealmB) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		fuzzAttribute(t, new(Realm), AttrRealm, value)
+	})
+}
+
+func FuzzNonce(f *testing.F) {
+	for _, seed := range textSeeds(maxNonceB) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		fuzzAttribute(t, new(Nonce), AttrNonce, value)
+	})
+}
+
+func FuzzSoftware(f *testing.F) {
+	for _, seed := range textSeeds(softwareRawMaxB) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value []byte) {
+		fuzzAttribute(t, new(Software), AttrSoftware, value)
+	})
+}
+
 func TestAttrPick(*testing.T) {
 	attrs := attributes{
 		{new(XORMappedAddress), AttrXORMappedAddress},
@@ -154,3 +304,57 @@ func (a attributes) pick(v byte) struct {
 
 	return a[idx]
 }
+
+// isURIError reports whether err is one of documented ParseURI errors.
+func isURIError(err error) bool {
+	for _, target := range []error{
+		ErrInvalidURI, ErrSchemeType, ErrHost, ErrPort,
+		ErrSTUNQuery, ErrInvalidQuery, ErrProtoType,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func FuzzParseURI(f *testing.F) {
+	for _, seed := range []string{
+		"stun:google.de",
+		"stuns:google.de:5349",
+		"stun:[::1]:123",
+		"stun:[fe80::1%25eth0]:3478",
+		"stun:[::1",
+		"stun:::1",
+		"turn:google.de?transport=udp",
+		"turns:google.de?transport=tcp&transport=udp",
+		"turn:google.de?transport=%zz",
+		"turn:google.de?;",
+		"turn:user:pass@google.de",
+		"turn://user@google.de:3478",
+		"stun:google.de:99999",
+		"stun:google.de:-1",
+		"stun:",
+		":::",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		uri, err := ParseURI(raw)
+		if err != nil {
+			if !isURIError(err) {
+				t.Fatalf("untyped error %q for %q", err, raw)
+			}
+
+			return
+		}
+		parsed, err := ParseURI(uri.String())
+		if err != nil {
+			t.Fatalf("failed to parse %q from %q: %v", uri, raw, err)
+		}
+		if *parsed != *uri {
+			t.Fatalf("%q from %q parsed as %q", uri, raw, parsed)
+		}
+	})
+}
@@ -7,6 +7,7 @@ require (
 	github.com/pion/logging v0.2.3
 	github.com/pion/transport/v3 v3.0.7
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/sys v0.26.0
 )
 
 require (
@@ -14,6 +15,5 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/wlynxg/anet v0.0.3 // indirect
 	golang.org/x/crypto v0.28.0 // indirect
-	golang.org/x/sys v0.26.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
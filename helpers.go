@@ -3,6 +3,11 @@
 
 package stun
 
+import (
+	"errors"
+	"fmt"
+)
+
 // Interfaces that are implemented by message attributes, shorthands for them,
 // or helpers for message fields as type or transaction id.
 type (
@@ -48,6 +53,41 @@ func (m *Message) Build(setters ...Setter) error {
 	return nil
 }
 
+// SetterErr records an error returned by Setter in BuildAll.
+//
+//nolint:errname
+type SetterErr struct {
+	Index  int    // index of setter in arguments
+	Setter Setter // setter that failed
+	Err    error  // error returned by setter
+}
+
+func (e *SetterErr) Error() string {
+	return fmt.Sprintf("setter %d (%T): %s", e.Index, e.Setter, e.Err)
+}
+
+// Unwrap returns error returned by setter.
+func (e *SetterErr) Unwrap() error {
+	return e.Err
+}
+
+// BuildAll is like Build, but applies all setters instead of returning
+// on first error. Each failure is wrapped into *SetterErr, and all of them
+// are joined via errors.Join, so errors.Is and errors.As can be used
+// to inspect them.
+func (m *Message) BuildAll(setters ...Setter) error {
+	m.Reset()
+	m.WriteHeader()
+	var errs []error
+	for i, s := range setters {
+		if err := s.AddTo(m); err != nil {
+			errs = append(errs, &SetterErr{Index: i, Setter: s, Err: err})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // Check applies checkers to message in batch, returning on first error.
 func (m *Message) Check(checkers ...Checker) error {
 	for _, c := range checkers {
@@ -90,6 +130,37 @@ func Build(setters ...Setter) (*Message, error) {
 	return m, nil
 }
 
+// Parse allocates new T and decodes it from m, allowing to get single
+// attribute in one expression:
+//
+//	addr, err := stun.Parse[stun.XORMappedAddress](m)
+//
+// where T is attribute type and *T implements Getter. Returns nil on error.
+func Parse[T any, PT interface {
+	*T
+	Getter
+}](m *Message) (PT, error) {
+	v := PT(new(T))
+	if err := v.GetFrom(m); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// MustParse wraps Parse call and panics on error.
+func MustParse[T any, PT interface {
+	*T
+	Getter
+}](m *Message) PT {
+	v, err := Parse[T, PT](m)
+	if err != nil {
+		panic(err) //nolint
+	}
+
+	return v
+}
+
 // ForEach is helper that iterates over message attributes allowing to call
 // Getter in f callback to get all attributes of type t and returning on first
 // f error.
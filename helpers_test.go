@@ -6,6 +6,7 @@ package stun
 import (
 	"errors"
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/pion/stun/v3/internal/testutil"
@@ -121,6 +122,65 @@ func TestHelpersErrorHandling(t *testing.T) {
 	})
 }
 
+func TestParse(t *testing.T) {
+	addr := &XORMappedAddress{IP: net.IPv4(213, 1, 223, 5), Port: 1234}
+	m := MustBuild(TransactionID, BindingSuccess, addr, NewSoftware("software"))
+	got, err := Parse[XORMappedAddress](m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IP.Equal(addr.IP) || got.Port != addr.Port {
+		t.Errorf("got %s, expected %s", got, addr)
+	}
+	if software := MustParse[Software](m); software.String() != "software" {
+		t.Errorf("unexpected software %s", software)
+	}
+	if _, err = Parse[Realm](m); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err = Parse[errReturner](m); err != nil {
+		t.Errorf("unexpected error %v", err) // zero errReturner returns nil
+	}
+	t.Run("MustParse", func(t *testing.T) {
+		defer func() {
+			if p, ok := recover().(error); !ok || !errors.Is(p, ErrAttributeNotFound) {
+				t.Errorf("unexpected panic %v", p)
+			}
+		}()
+		MustParse[Realm](m)
+	})
+}
+
+func TestMessage_BuildAll(t *testing.T) {
+	m := New()
+	errOther := errors.New("other") //nolint:err113
+	err := m.BuildAll(
+		errReturner{Err: errTError},
+		BindingRequest,
+		NewSoftware("software"),
+		errReturner{Err: errOther},
+	)
+	if !errors.Is(err, errTError) || !errors.Is(err, errOther) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var setterErr *SetterErr
+	if !errors.As(err, &setterErr) {
+		t.Fatal("should be SetterErr")
+	}
+	if setterErr.Index != 0 {
+		t.Errorf("unexpected index %d", setterErr.Index)
+	}
+	if setterErr.Error() != "setter 0 (stun.errReturner): "+errTError.Error() {
+		t.Errorf("unexpected string %q", setterErr)
+	}
+	if m.Type != BindingRequest || !m.Contains(AttrSoftware) {
+		t.Error("should apply all setters")
+	}
+	if err := m.BuildAll(BindingRequest, NewSoftware("software")); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestMessage_ForEach(t *testing.T) { //nolint:cyclop
 	initial := New()
 	if err := initial.Build(
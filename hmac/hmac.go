@@ -17,6 +17,9 @@ timing side-channels:
 		expectedMAC := mac.Sum(nil)
 		return hmac.Equal(messageMAC, expectedMAC)
 	}
+
+Unlike crypto/hmac, HMAC instances can be reused via Pool to avoid
+allocations, see NewPool, AcquireSHA1 and AcquireSHA256.
 */
 package hmac
 
@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package hmac
+
+import ( //nolint:gci
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"hash"
+	"sync"
+)
+
+func (h *hmac) resetTo(key []byte) {
+	h.outer.Reset()
+	h.inner.Reset()
+	blocksize := h.inner.BlockSize()
+
+	// Reset size and zero of ipad and opad.
+	h.ipad = append(h.ipad[:0], make([]byte, blocksize)...)
+	h.opad = append(h.opad[:0], make([]byte, blocksize)...)
+
+	if len(key) > blocksize {
+		// If key is too big, hash it.
+		h.outer.Write(key) //nolint:errcheck,gosec
+		key = h.outer.Sum(nil)
+	}
+	copy(h.ipad, key)
+	copy(h.opad, key)
+	for i := range h.ipad {
+		h.ipad[i] ^= 0x36
+	}
+	for i := range h.opad {
+		h.opad[i] ^= 0x5c
+	}
+	h.inner.Write(h.ipad) //nolint:errcheck,gosec
+
+	h.marshaled = false
+}
+
+// Pool is a pool of HMAC instances for the hash constructor, enabling
+// zero-allocation HMAC computation with arbitrary hash function.
+//
+// Pool is safe for concurrent use.
+type Pool struct {
+	size      int
+	blockSize int
+	pool      sync.Pool
+}
+
+// NewPool returns new Pool of HMAC instances that are using h.
+func NewPool(h func() hash.Hash) *Pool {
+	sample := h()
+	p := &Pool{
+		size:      sample.Size(),
+		blockSize: sample.BlockSize(),
+	}
+	p.pool.New = func() interface{} {
+		return New(h, make([]byte, p.blockSize))
+	}
+
+	return p
+}
+
+// Acquire returns HMAC with provided key from pool.
+func (p *Pool) Acquire(key []byte) hash.Hash {
+	h := p.pool.Get().(*hmac) //nolint:forcetypeassert
+	assertHMACSize(h, p.size, p.blockSize)
+	h.resetTo(key)
+
+	return h
+}
+
+// Put puts h to pool. The h must be acquired from same pool
+// and should not be used after Put call.
+func (p *Pool) Put(h hash.Hash) {
+	hm := h.(*hmac) //nolint:forcetypeassert
+	assertHMACSize(hm, p.size, p.blockSize)
+	p.pool.Put(hm)
+}
+
+// KeyedPool is a pool of HMAC instances that are keyed once on creation,
+// so acquiring is cheaper than Pool.Acquire when key is always the same.
+//
+// KeyedPool is safe for concurrent use.
+type KeyedPool struct {
+	size      int
+	blockSize int
+	pool      sync.Pool
+}
+
+// NewKeyedPool returns new KeyedPool of HMAC instances that are using h
+// and key.
+func NewKeyedPool(h func() hash.Hash, key []byte) *KeyedPool {
+	key = append([]byte(nil), key...)
+	sample := h()
+	p := &KeyedPool{
+		size:      sample.Size(),
+		blockSize: sample.BlockSize(),
+	}
+	p.pool.New = func() interface{} {
+		mac := New(h, key)
+		// Saving internal state, so subsequent resets are cheap.
+		mac.Reset()
+
+		return mac
+	}
+
+	return p
+}
+
+// Acquire returns reset HMAC from pool.
+func (p *KeyedPool) Acquire() hash.Hash {
+	h := p.pool.Get().(*hmac) //nolint:forcetypeassert
+	h.Reset()
+
+	return h
+}
+
+// Put puts h to pool. The h must be acquired from same pool
+// and should not be used after Put call.
+func (p *KeyedPool) Put(h hash.Hash) {
+	hm := h.(*hmac) //nolint:forcetypeassert
+	assertHMACSize(hm, p.size, p.blockSize)
+	p.pool.Put(hm)
+}
+
+var (
+	hmacSHA1Pool   = NewPool(sha1.New)   //nolint:gochecknoglobals
+	hmacSHA256Pool = NewPool(sha256.New) //nolint:gochecknoglobals
+)
+
+// AcquireSHA1 returns new HMAC from pool.
+func AcquireSHA1(key []byte) hash.Hash {
+	return hmacSHA1Pool.Acquire(key)
+}
+
+// PutSHA1 puts h to pool.
+func PutSHA1(h hash.Hash) {
+	hmacSHA1Pool.Put(h)
+}
+
+// AcquireSHA256 returns new HMAC from SHA256 pool.
+func AcquireSHA256(key []byte) hash.Hash {
+	return hmacSHA256Pool.Acquire(key)
+}
+
+// PutSHA256 puts h to SHA256 pool.
+func PutSHA256(h hash.Hash) {
+	hmacSHA256Pool.Put(h)
+}
+
+// assertHMACSize panics if h.size != size or h.blocksize != blocksize.
+//
+// Putting HMAC from another pool is programmer error, so
+// checking it via such assert is optimal.
+func assertHMACSize(h *hmac, size, blocksize int) {
+	if h.Size() != size || h.BlockSize() != blocksize {
+		panic("BUG: hmac size invalid") //nolint
+	}
+}
@@ -6,6 +6,7 @@ package hmac
 import ( //nolint:gci
 	"crypto/sha1" //nolint:gosec
 	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
 	"testing"
 )
@@ -142,6 +143,74 @@ func TestHMACPool_SHA256(t *testing.T) { //nolint:dupl,cyclop
 	}
 }
 
+func TestPool(t *testing.T) {
+	for i, tt := range hmacTests() {
+		pool := NewPool(tt.hash)
+		hsh := pool.Acquire(tt.key)
+		if s := hsh.Size(); s != tt.size {
+			t.Errorf("Size: got %v, want %v", s, tt.size)
+		}
+		if b := hsh.BlockSize(); b != tt.blocksize {
+			t.Errorf("BlockSize: got %v, want %v", b, tt.blocksize)
+		}
+		if n, err := hsh.Write(tt.in); n != len(tt.in) || err != nil {
+			t.Errorf("test %d: Write(%d) = %d, %v", i, len(tt.in), n, err)
+		}
+		if sum := fmt.Sprintf("%x", hsh.Sum(nil)); sum != tt.out {
+			t.Errorf("test %d: have %s want %s", i, sum, tt.out)
+		}
+		pool.Put(hsh)
+	}
+	t.Run("PutForeign", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("should panic")
+			}
+		}()
+		NewPool(sha1.New).Put(AcquireSHA256(nil))
+	})
+}
+
+func TestKeyedPool(t *testing.T) {
+	for i, tt := range hmacTests() {
+		pool := NewKeyedPool(tt.hash, tt.key)
+		for j := 0; j < 2; j++ {
+			hsh := pool.Acquire()
+			if n, err := hsh.Write(tt.in); n != len(tt.in) || err != nil {
+				t.Errorf("test %d: Write(%d) = %d, %v", i, len(tt.in), n, err)
+			}
+			if sum := fmt.Sprintf("%x", hsh.Sum(nil)); sum != tt.out {
+				t.Errorf("test %d: have %s want %s", i, sum, tt.out)
+			}
+			pool.Put(hsh)
+		}
+	}
+	t.Run("PutForeign", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("should panic")
+			}
+		}()
+		NewKeyedPool(sha1.New, nil).Put(AcquireSHA256(nil))
+	})
+}
+
+func BenchmarkPool_SHA512(b *testing.B) {
+	pool := NewPool(sha512.New)
+	key := make([]byte, 32)
+	buf := make([]byte, 512)
+	tBuf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		h := pool.Acquire(key)
+		h.Write(buf) //nolint:errcheck,gosec
+		mac := h.Sum(tBuf)
+		buf[0] = mac[0]
+		pool.Put(h)
+	}
+}
+
 func TestAssertBlockSize(t *testing.T) {
 	t.Run("Positive", func(*testing.T) {
 		h := AcquireSHA1(make([]byte, 0, 1024))
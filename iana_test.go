@@ -121,3 +121,39 @@ func TestIANA(t *testing.T) { //nolint:cyclop
 		}
 	})
 }
+
+func TestNameRegistries(t *testing.T) {
+	t.Run("AttrTypeNames", func(t *testing.T) {
+		names := AttrTypeNames()
+		if names[AttrMappedAddress] != AttrMappedAddress.String() {
+			t.Errorf("unexpected name %q", names[AttrMappedAddress])
+		}
+		names[AttrMappedAddress] = "MODIFIED"
+		if AttrMappedAddress.String() != "MAPPED-ADDRESS" {
+			t.Error("registry should not be modified")
+		}
+	})
+	t.Run("MethodNames", func(t *testing.T) {
+		names := MethodNames()
+		if names[MethodBinding] != MethodBinding.String() {
+			t.Errorf("unexpected name %q", names[MethodBinding])
+		}
+		names[MethodBinding] = "Modified"
+		if MethodBinding.String() != "Binding" {
+			t.Error("registry should not be modified")
+		}
+	})
+	t.Run("ErrorCodeReasons", func(t *testing.T) {
+		reasons := ErrorCodeReasons()
+		if len(reasons) != len(errorReasons) {
+			t.Errorf("unexpected length %d", len(reasons))
+		}
+		if reasons[CodeUnauthorized] != "Unauthorized" {
+			t.Errorf("unexpected reason %q", reasons[CodeUnauthorized])
+		}
+		reasons[CodeUnauthorized] = "Modified"
+		if string(errorReasons[CodeUnauthorized]) != "Unauthorized" {
+			t.Error("registry should not be modified")
+		}
+	})
+}
@@ -8,9 +8,11 @@ import ( //nolint:gci
 	"crypto/sha1" //nolint:gosec
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"strings"
 
-	"github.com/pion/stun/v3/internal/hmac"
+	"github.com/pion/stun/v3/hmac"
 )
 
 // separator for credentials.
@@ -20,8 +22,8 @@ const credentialsSep = ":"
 // credentials. Password, username, and realm must be SASL-prepared.
 func NewLongTermIntegrity(username, realm, password string) MessageIntegrity {
 	k := strings.Join([]string{username, realm, password}, credentialsSep)
-	h := md5.New()   //nolint:gosec
-	fmt.Fprint(h, k) //nolint:errcheck
+	h := md5.New()       //nolint:gosec
+	io.WriteString(h, k) //nolint:errcheck,gosec
 
 	return MessageIntegrity(h.Sum(nil))
 }
@@ -35,7 +37,7 @@ func NewShortTermIntegrity(password string) MessageIntegrity {
 // MessageIntegrity represents MESSAGE-INTEGRITY attribute.
 //
 // AddTo and Check methods are using zero-allocation version of hmac, see
-// newHMAC function and internal/hmac/pool.go.
+// newHMAC function and hmac/pool.go.
 //
 // RFC 5389 Section 15.4.
 type MessageIntegrity []byte
@@ -96,6 +98,15 @@ var ErrIntegrityMismatch = errors.New("integrity check failed")
 //
 // CPU costly, see BenchmarkMessageIntegrity_Check.
 func (i MessageIntegrity) Check(msg *Message) error {
+	mac := hmac.AcquireSHA1(i)
+	defer hmac.PutSHA1(mac)
+
+	return checkIntegrity(msg, mac)
+}
+
+// checkIntegrity checks MESSAGE-INTEGRITY attribute using mac that
+// is already initialized with key.
+func checkIntegrity(msg *Message, mac hash.Hash) error {
 	val, err := msg.Get(AttrMessageIntegrity)
 	if err != nil {
 		return err
@@ -122,9 +133,38 @@ func (i MessageIntegrity) Check(msg *Message) error {
 	// startOfHMAC should be first byte of integrity attribute.
 	startOfHMAC := messageHeaderSize + msg.Length - (attributeHeaderSize + messageIntegritySize)
 	b := msg.Raw[:startOfHMAC] // data before integrity attribute
-	expected := newHMAC(i, b, msg.Raw[len(msg.Raw):])
+	writeOrPanic(mac, b)
+	expected := mac.Sum(msg.Raw[len(msg.Raw):])
 	msg.Length = length
 	msg.WriteLength() // writing length back
 
 	return checkHMAC(val, expected)
 }
+
+// IntegrityVerifier checks MESSAGE-INTEGRITY attribute of messages
+// that share the same key, e.g. requests from single user.
+//
+// Unlike MessageIntegrity.Check, key setup is done once in
+// NewIntegrityVerifier instead of on each call, see
+// BenchmarkIntegrityVerifier_Check.
+//
+// Safe for concurrent use, concurrent checks use different HMAC instances.
+type IntegrityVerifier struct {
+	pool *hmac.KeyedPool
+}
+
+// NewIntegrityVerifier returns new IntegrityVerifier for key, that can be
+// obtained via NewLongTermIntegrity or NewShortTermIntegrity.
+func NewIntegrityVerifier(key MessageIntegrity) *IntegrityVerifier {
+	return &IntegrityVerifier{
+		pool: hmac.NewKeyedPool(sha1.New, key),
+	}
+}
+
+// Check checks MESSAGE-INTEGRITY attribute.
+func (v *IntegrityVerifier) Check(msg *Message) error {
+	mac := v.pool.Acquire()
+	defer v.pool.Put(mac)
+
+	return checkIntegrity(msg, mac)
+}
@@ -6,6 +6,7 @@ package stun
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"testing"
 )
 
@@ -124,3 +125,90 @@ func BenchmarkMessageIntegrity_Check(b *testing.B) {
 		}
 	}
 }
+
+func TestIntegrityVerifier(t *testing.T) {
+	integrity := NewLongTermIntegrity("user", "realm", "pass")
+	verifier := NewIntegrityVerifier(integrity)
+	for _, software := range []string{"a", "software", "longer software"} {
+		m := new(Message)
+		m.WriteHeader()
+		NewSoftware(software).AddTo(m) //nolint:errcheck,gosec
+		if err := integrity.AddTo(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := Fingerprint.AddTo(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.Check(m); err != nil {
+			t.Errorf("%q: %v", software, err)
+		}
+		m.Raw[len(m.Raw)-12] ^= 1 // HMAC now invalid
+		if err := verifier.Check(m); !errors.Is(err, ErrIntegrityMismatch) {
+			t.Errorf("%q: unexpected error: %v", software, err)
+		}
+	}
+	if err := verifier.Check(MustBuild(BindingRequest)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func BenchmarkIntegrityVerifier_Check(b *testing.B) {
+	m := MustBuild(BindingRequest, NewSoftware("software"),
+		NewLongTermIntegrity("user", "realm", "pass"),
+	)
+	b.Run("PerMessage", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := NewLongTermIntegrity("user", "realm", "pass").Check(m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Verifier", func(b *testing.B) {
+		verifier := NewIntegrityVerifier(NewLongTermIntegrity("user", "realm", "pass"))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := verifier.Check(m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// Check modifies length of message, so each goroutine has its own.
+	b.Run("PerMessageParallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			msg := new(Message)
+			if err := m.CloneTo(msg); err != nil {
+				b.Error(err)
+
+				return
+			}
+			for pb.Next() {
+				if err := NewLongTermIntegrity("user", "realm", "pass").Check(msg); err != nil {
+					b.Error(err)
+
+					return
+				}
+			}
+		})
+	})
+	b.Run("VerifierParallel", func(b *testing.B) {
+		verifier := NewIntegrityVerifier(NewLongTermIntegrity("user", "realm", "pass"))
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			msg := new(Message)
+			if err := m.CloneTo(msg); err != nil {
+				b.Error(err)
+
+				return
+			}
+			for pb.Next() {
+				if err := verifier.Check(msg); err != nil {
+					b.Error(err)
+
+					return
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+// attrLookupThreshold is minimum number of attributes for which
+// BuildIndex builds lookup index, linear scan is faster for smaller
+// messages.
+const attrLookupThreshold = 8
+
+// attrLookup is index of first attribute position by type, see
+// Message.BuildIndex.
+//
+// Index is bound to the Attributes slice it was built for and is not used
+// if length or backing array of Message.Attributes changes, e.g. in
+// ForEach callback. The map is reused between builds to not allocate for
+// pooled messages.
+type attrLookup struct {
+	positions map[AttrType]int
+	n         int           // len(Attributes) when built
+	base      *RawAttribute // &Attributes[0] when built
+}
+
+func (l *attrLookup) valid(attrs Attributes) bool {
+	return l.base != nil && l.n == len(attrs) && &attrs[0] == l.base
+}
+
+func (l *attrLookup) reset() {
+	l.base = nil
+}
+
+func (l *attrLookup) build(attrs Attributes) {
+	if l.positions == nil {
+		l.positions = make(map[AttrType]int, len(attrs))
+	}
+	for t := range l.positions {
+		delete(l.positions, t)
+	}
+	// Iterating backwards so first attribute of type wins.
+	for i := len(attrs) - 1; i >= 0; i-- {
+		l.positions[attrs[i].Type] = i
+	}
+	l.n = len(attrs)
+	l.base = &attrs[0]
+}
+
+// BuildIndex builds index of m.Attributes by type, so Get, Replace and
+// Del don't scan all attributes of messages with many of them, e.g. TURN
+// Allocate responses. Index is dropped by Add, Del, Decode and Reset.
+// It is not updated if Attributes are modified directly, so BuildIndex
+// should be called again after that.
+//
+// Get does not modify m, so concurrent Get calls are safe both with and
+// without index.
+func (m *Message) BuildIndex() {
+	if len(m.Attributes) < attrLookupThreshold {
+		m.lookup.reset()
+
+		return
+	}
+	m.lookup.build(m.Attributes)
+}
+
+// attrIndex returns index of the first attribute with type t or -1.
+func (m *Message) attrIndex(t AttrType) int {
+	if m.lookup.valid(m.Attributes) {
+		i, ok := m.lookup.positions[t]
+		if !ok {
+			return -1
+		}
+
+		return i
+	}
+	for i, a := range m.Attributes {
+		if a.Type == t {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+// newManyAttrsMessage returns message with n attributes of types
+// 0x8000+i and value {i}.
+func newManyAttrsMessage(n int) *Message {
+	m := New()
+	m.WriteHeader()
+	for i := 0; i < n; i++ {
+		m.Add(AttrType(0x8000+i), []byte{byte(i)})
+	}
+
+	return m
+}
+
+func BenchmarkMessage_GetMany(b *testing.B) {
+	m := newManyAttrsMessage(16)
+	m.BuildIndex()
+	last := AttrType(0x8000 + 15)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Get(last) //nolint:errcheck,gosec
+	}
+}
+
+func TestMessage_GetLookup(t *testing.T) { //nolint:cyclop
+	const n = attrLookupThreshold * 2
+	m := newManyAttrsMessage(n)
+	m.Add(AttrType(0x8000), []byte{0xff}) // duplicate, should not be returned
+	m.BuildIndex()
+	check := func(t AttrType, expected byte) error {
+		v, err := m.Get(t)
+		if err != nil {
+			return err
+		}
+		if len(v) != 1 || v[0] != expected {
+			return ErrAttributeSizeInvalid
+		}
+
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		if err := check(AttrType(0x8000+i), byte(i)); err != nil {
+			t.Errorf("0x%x: %v", 0x8000+i, err)
+		}
+	}
+	if _, err := m.Get(AttrRealm); err != ErrAttributeNotFound { //nolint:errorlint
+		t.Errorf("unexpected error %v", err)
+	}
+	t.Run("ZeroAlloc", func(t *testing.T) {
+		testutil.ShouldNotAllocate(t, func() {
+			m.Get(AttrType(0x8000 + n - 1)) //nolint:errcheck,gosec
+		})
+	})
+	t.Run("Truncate", func(t *testing.T) {
+		// Index is not used for other Attributes slice.
+		attrs := m.Attributes
+		m.Attributes = attrs[:n-1]
+		if _, err := m.Get(AttrType(0x8000 + n - 1)); err != ErrAttributeNotFound { //nolint:errorlint
+			t.Errorf("unexpected error %v", err)
+		}
+		m.Attributes = attrs
+	})
+	t.Run("ForEach", func(t *testing.T) {
+		var values []byte
+		if err := m.ForEach(AttrType(0x8000), func(m *Message) error {
+			v, err := m.Get(AttrType(0x8000))
+			values = append(values, v...)
+
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 2 || values[0] != 0 || values[1] != 0xff {
+			t.Errorf("unexpected values %v", values)
+		}
+	})
+	t.Run("Decode", func(t *testing.T) {
+		// Same number of attributes with other types and values.
+		b := New()
+		b.WriteHeader()
+		for i := 0; i <= n; i++ {
+			b.Add(AttrType(0x9000+i), []byte{byte(i + 1)})
+		}
+		if err := b.CloneTo(m); err != nil {
+			t.Fatal(err)
+		}
+		if m.lookup.valid(m.Attributes) {
+			t.Error("index should be dropped by Decode")
+		}
+		m.BuildIndex()
+		if _, err := m.Get(AttrType(0x8000)); err != ErrAttributeNotFound { //nolint:errorlint
+			t.Errorf("unexpected error %v", err)
+		}
+		if err := check(AttrType(0x9000+n), byte(n+1)); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("Del", func(t *testing.T) {
+		if !m.Del(AttrType(0x9000)) {
+			t.Fatal("not deleted")
+		}
+		if m.lookup.valid(m.Attributes) {
+			t.Error("index should be dropped by Del")
+		}
+		if err := check(AttrType(0x9001), 2); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestMessage_GetNoIndex(t *testing.T) {
+	m := newManyAttrsMessage(attrLookupThreshold * 2)
+	if _, err := m.Get(AttrType(0x8001)); err != nil {
+		t.Fatal(err)
+	}
+	if m.lookup.base != nil {
+		t.Error("Get should not build index")
+	}
+	m.BuildIndex()
+	m.Add(AttrType(0x9000), []byte{1})
+	if m.lookup.valid(m.Attributes) {
+		t.Error("index should be dropped by Add")
+	}
+	if _, err := m.Get(AttrType(0x9000)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMessage_GetConcurrent(t *testing.T) {
+	const n = attrLookupThreshold + 4
+	decoded := new(Message)
+	if err := Decode(newManyAttrsMessage(n).Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, index := range []bool{false, true} {
+		if index {
+			decoded.BuildIndex()
+		}
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					if v, err := decoded.Get(AttrType(0x8000 + i)); err != nil || v[0] != byte(i) {
+						t.Errorf("index %v: 0x%x: %v, %v", index, 0x8000+i, v, err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -6,9 +6,12 @@ package stun
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"io"
+	"net"
+	"sort"
+	"strconv"
 )
 
 const (
@@ -36,6 +39,38 @@ func NewTransactionID() (b [TransactionIDSize]byte) {
 	return b
 }
 
+// TransactionIDString returns id encoded as base64 without padding, the
+// format used by Message.String.
+func TransactionIDString(id [TransactionIDSize]byte) string {
+	return base64.StdEncoding.EncodeToString(id[:])
+}
+
+// ErrInvalidTransactionID means that string is not valid transaction id.
+var ErrInvalidTransactionID = errors.New("invalid transaction id")
+
+// ParseTransactionID parses transaction id from base64 (as returned by
+// TransactionIDString) or hex string, which are distinguished by length.
+func ParseTransactionID(s string) ([TransactionIDSize]byte, error) {
+	var (
+		id  [TransactionIDSize]byte
+		n   int
+		err error
+	)
+	switch len(s) {
+	case base64.StdEncoding.EncodedLen(TransactionIDSize):
+		n, err = base64.StdEncoding.Decode(id[:], []byte(s))
+	case hex.EncodedLen(TransactionIDSize):
+		n, err = hex.Decode(id[:], []byte(s))
+	default:
+		return id, ErrInvalidTransactionID
+	}
+	if err != nil || n != TransactionIDSize {
+		return [TransactionIDSize]byte{}, ErrInvalidTransactionID
+	}
+
+	return id, nil
+}
+
 // IsMessage returns true if b looks like STUN message.
 // Useful for multiplexing. IsMessage does not guarantee
 // that decoding will be successful.
@@ -43,6 +78,50 @@ func IsMessage(b []byte) bool {
 	return len(b) >= messageHeaderSize && bin.Uint32(b[4:8]) == magicCookie
 }
 
+// IsMessageStrict is stronger variant of IsMessage that additionally checks
+// that two most significant bits of message type are zero, message length
+// is padded and fits into b, attribute lengths are consistent with message
+// length, and, if FINGERPRINT attribute is present, that it is the last one
+// and its value is valid.
+//
+// Useful for multiplexing on ports shared with arbitrary application data.
+// Like IsMessage, does not allocate.
+func IsMessageStrict(b []byte) bool {
+	if !IsMessage(b) || b[0]&0xc0 != 0 {
+		return false
+	}
+	size := int(bin.Uint16(b[2:4]))
+	end := messageHeaderSize + size
+	if size%padding != 0 || len(b) < end {
+		return false
+	}
+	offset := messageHeaderSize
+	for offset < end {
+		if end-offset < attributeHeaderSize {
+			return false
+		}
+		var (
+			t      = AttrType(bin.Uint16(b[offset : offset+2]))
+			length = int(bin.Uint16(b[offset+2 : offset+4]))
+			next   = offset + attributeHeaderSize + nearestPaddedValueLength(length)
+		)
+		if next > end {
+			return false
+		}
+		if t == AttrFingerprint {
+			value := b[offset+attributeHeaderSize : next]
+			if length != fingerprintSize || next != end {
+				return false
+			}
+
+			return bin.Uint32(value) == FingerprintValue(b[:offset])
+		}
+		offset = next
+	}
+
+	return true
+}
+
 // New returns *Message with pre-allocated Raw.
 func New() *Message {
 	const defaultRawCapacity = 120
@@ -71,12 +150,16 @@ func Decode(data []byte, m *Message) error {
 //
 //	Message, its fields, results of m.Get or any attribute a.GetFrom
 //	are valid only until Message.Raw is not modified.
+//
+// Use m.BuildIndex to speed up m.Get for messages with many attributes.
 type Message struct {
 	Type          MessageType
 	Length        uint32 // len(Raw) not including header
 	TransactionID [TransactionIDSize]byte
 	Attributes    Attributes
 	Raw           []byte
+
+	lookup attrLookup
 }
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
@@ -129,13 +212,16 @@ func (m *Message) NewTransactionID() error {
 }
 
 func (m *Message) String() string {
-	tID := base64.StdEncoding.EncodeToString(m.TransactionID[:])
+	tID := TransactionIDString(m.TransactionID)
 	aInfo := ""
 	for k, a := range m.Attributes {
-		aInfo += fmt.Sprintf("attr%d=%s ", k, a.Type)
+		aInfo += "attr" + strconv.Itoa(k) + "=" + a.Type.String() + " "
 	}
 
-	return fmt.Sprintf("%s l=%d attrs=%d id=%s, %s", m.Type, m.Length, len(m.Attributes), tID, aInfo)
+	return m.Type.String() +
+		" l=" + strconv.FormatUint(uint64(m.Length), 10) +
+		" attrs=" + strconv.Itoa(len(m.Attributes)) +
+		" id=" + tID + ", " + aInfo
 }
 
 // Reset resets Message, attributes and underlying buffer length.
@@ -143,6 +229,7 @@ func (m *Message) Reset() {
 	m.Raw = m.Raw[:0]
 	m.Length = 0
 	m.Attributes = m.Attributes[:0]
+	m.lookup.reset()
 }
 
 // grow ensures that internal buffer has n length.
@@ -188,6 +275,7 @@ func (m *Message) Add(attrType AttrType, val []byte) {
 		//nolint:gosec // G115
 		Length: uint16(len(val)), // L
 		Value:  value,            // V
+		Offset: first,
 	}
 
 	// Encoding attribute TLV to allocated buffer.
@@ -213,9 +301,88 @@ func (m *Message) Add(attrType AttrType, val []byte) {
 		m.Length += uint32(bytesToAdd) // rendering length change
 	}
 	m.Attributes = append(m.Attributes, attr)
+	m.lookup.reset()
 	m.WriteLength()
 }
 
+// Replace sets value of the first attribute with type t to v, re-encoding
+// m.Raw and fixing lengths even if len(v) differs from current value
+// length. Attribute keeps its position. Not goroutine-safe.
+//
+// Returns ErrAttributeNotFound if there is no such attribute. Value is
+// copied to internal buffer so it is safe to reuse v. Note that
+// MESSAGE-INTEGRITY and FINGERPRINT added before are not updated.
+func (m *Message) Replace(t AttrType, v []byte) error {
+	idx := m.attrIndex(t)
+	if idx < 0 {
+		return ErrAttributeNotFound
+	}
+	var (
+		a      = m.Attributes[idx]
+		start  = a.Offset + attributeHeaderSize // first byte of value
+		oldEnd = start + nearestPaddedValueLength(int(a.Length))
+		newEnd = start + nearestPaddedValueLength(len(v))
+		delta  = newEnd - oldEnd
+		length = int(m.Length) + delta
+		size   = len(m.Raw)
+	)
+	if len(v) > 0xFFFF || length > 0xFFFF {
+		return ErrAttributeSizeOverflow
+	}
+	m.grow(size + delta)
+	copy(m.Raw[newEnd:], m.Raw[oldEnd:size]) // shifting following attributes
+	m.Raw = m.Raw[:size+delta]
+	copy(m.Raw[start:newEnd], v)
+	for i := start + len(v); i < newEnd; i++ {
+		m.Raw[i] = 0 // padding
+	}
+	bin.PutUint16(m.Raw[a.Offset+2:a.Offset+4], uint16(len(v))) //nolint:gosec // G115
+	m.Attributes[idx].Length = uint16(len(v))                   //nolint:gosec // G115
+	// Raw could be reallocated, so updating all values.
+	for i := range m.Attributes {
+		attr := &m.Attributes[i]
+		if i > idx {
+			attr.Offset += delta
+		}
+		first := attr.Offset + attributeHeaderSize
+		attr.Value = m.Raw[first : first+int(attr.Length)]
+	}
+	m.Length = uint32(length) //nolint:gosec // G115
+	m.WriteLength()
+
+	return nil
+}
+
+// Del removes the first attribute with type t, compacting m.Raw and fixing
+// length. Returns false if there is no such attribute. Not goroutine-safe.
+//
+// Note that MESSAGE-INTEGRITY and FINGERPRINT added before are not updated.
+func (m *Message) Del(t AttrType) bool {
+	idx := m.attrIndex(t)
+	if idx < 0 {
+		return false
+	}
+	var (
+		a     = m.Attributes[idx]
+		end   = a.Offset + attributeHeaderSize + nearestPaddedValueLength(int(a.Length))
+		delta = end - a.Offset
+	)
+	copy(m.Raw[a.Offset:], m.Raw[end:]) // shifting following attributes
+	m.Raw = m.Raw[:len(m.Raw)-delta]
+	m.Attributes = append(m.Attributes[:idx], m.Attributes[idx+1:]...)
+	m.lookup.reset()
+	for i := idx; i < len(m.Attributes); i++ {
+		attr := &m.Attributes[i]
+		attr.Offset -= delta
+		first := attr.Offset + attributeHeaderSize
+		attr.Value = m.Raw[first : first+int(attr.Length)]
+	}
+	m.Length -= uint32(delta) //nolint:gosec // G115
+	m.WriteLength()
+
+	return true
+}
+
 func attrSliceEqual(a, b Attributes) bool {
 	for _, attr := range a {
 		found := false
@@ -334,6 +501,55 @@ func (m *Message) Encode() {
 	m.WriteAttributes()
 }
 
+// EncodeCanonical re-encodes message into m.Raw with attributes sorted by
+// type, keeping relative order of attributes with same type. The
+// MESSAGE-INTEGRITY, MESSAGE-INTEGRITY-SHA256 and FINGERPRINT attributes
+// are placed last in that order.
+//
+// Useful for golden-file tests and cross-implementation comparisons that
+// need byte-identical output. Integrity and fingerprint values are not
+// recomputed, so call EncodeCanonical before adding them.
+func (m *Message) EncodeCanonical() {
+	var (
+		attributes = make(Attributes, len(m.Attributes))
+		size       = 0
+	)
+	for _, a := range m.Attributes {
+		size += len(a.Value)
+	}
+	// Values are referencing m.Raw that is overwritten during encoding.
+	values := make([]byte, 0, size)
+	for i, a := range m.Attributes {
+		values = append(values, a.Value...)
+		attributes[i] = a
+		attributes[i].Value = values[len(values)-len(a.Value):]
+	}
+	sort.SliceStable(attributes, func(i, j int) bool {
+		a, b := attributes[i].Type, attributes[j].Type
+		if rankA, rankB := canonicalRank(a), canonicalRank(b); rankA != rankB {
+			return rankA < rankB
+		}
+
+		return a < b
+	})
+	m.Attributes = attributes
+	m.Encode()
+}
+
+// canonicalRank returns position of attribute type group in canonical order.
+func canonicalRank(t AttrType) int {
+	switch t {
+	case AttrMessageIntegrity:
+		return 1
+	case AttrMessageIntegritySHA256:
+		return 2
+	case AttrFingerprint:
+		return 3
+	default:
+		return 0
+	}
+}
+
 // WriteTo implements WriterTo via calling Write(m.Raw) on w and returning
 // call result.
 func (m *Message) WriteTo(w io.Writer) (int64, error) {
@@ -342,6 +558,73 @@ func (m *Message) WriteTo(w io.Writer) (int64, error) {
 	return int64(n), err
 }
 
+// ErrTrailingAttributes means that message already contains MESSAGE-INTEGRITY
+// or FINGERPRINT, so no attributes can be appended after them.
+var ErrTrailingAttributes = errors.New("MESSAGE-INTEGRITY or FINGERPRINT before appended attribute")
+
+// WriteBuffersTo writes m with appended attribute of type t and value v
+// to w, without copying v to m.Raw. Useful for large attributes, e.g. DATA
+// of TURN Send indication.
+//
+// If w is *net.TCPConn, *net.UDPConn, *net.UnixConn or *net.IPConn, the
+// message is written via net.Buffers with single vectored write (writev).
+// Otherwise, the message is copied to temporary buffer and written with
+// single Write call to preserve datagram boundaries.
+//
+// The m is not modified. Because attribute is appended last, m should not
+// contain MESSAGE-INTEGRITY or FINGERPRINT, otherwise ErrTrailingAttributes
+// is returned.
+func (m *Message) WriteBuffersTo(w io.Writer, t AttrType, v []byte) (int64, error) {
+	if m.Contains(AttrMessageIntegrity) || m.Contains(AttrFingerprint) {
+		return 0, ErrTrailingAttributes
+	}
+	bodyEnd := messageHeaderSize + int(m.Length)
+	if len(m.Raw) < bodyEnd {
+		return 0, ErrUnexpectedHeaderEOF
+	}
+	valueLength := nearestPaddedValueLength(len(v))
+	length := int(m.Length) + attributeHeaderSize + valueLength
+	if len(v) > 0xFFFF || length > 0xFFFF {
+		return 0, ErrAttributeSizeOverflow
+	}
+	header := make([]byte, messageHeaderSize+attributeHeaderSize+padding)
+	copy(header, m.Raw[:messageHeaderSize])
+	bin.PutUint16(header[2:4], uint16(length)) //nolint:gosec // G115
+	tlv := header[messageHeaderSize : messageHeaderSize+attributeHeaderSize]
+	bin.PutUint16(tlv[0:2], t.Value())
+	bin.PutUint16(tlv[2:4], uint16(len(v))) //nolint:gosec // G115
+	// Zeroes after TLV header are used for padding.
+	pad := header[messageHeaderSize+attributeHeaderSize:][:valueLength-len(v)]
+	buffers := net.Buffers{
+		header[:messageHeaderSize],
+		m.Raw[messageHeaderSize:bodyEnd],
+		tlv,
+		v,
+		pad,
+	}
+	if supportsVectoredWrite(w) {
+		return buffers.WriteTo(w)
+	}
+	buf := make([]byte, 0, messageHeaderSize+length)
+	for _, b := range buffers {
+		buf = append(buf, b...)
+	}
+	n, err := w.Write(buf)
+
+	return int64(n), err
+}
+
+// supportsVectoredWrite returns true if net.Buffers uses single writev call
+// for w instead of calling w.Write for each buffer.
+func supportsVectoredWrite(w io.Writer) bool {
+	switch w.(type) {
+	case *net.TCPConn, *net.UDPConn, *net.UnixConn, *net.IPConn:
+		return true
+	default:
+		return false
+	}
+}
+
 // ReadFrom implements ReaderFrom. Reads message from r into m.Raw,
 // Decodes it and return error if any. If m.Raw is too small, will return
 // ErrUnexpectedEOF, ErrUnexpectedHeaderEOF or *DecodeErr.
@@ -379,12 +662,14 @@ func (m *Message) Decode() error {
 		fullSize = messageHeaderSize + size  // len(m.Raw)
 	)
 	if cookie != magicCookie {
-		msg := fmt.Sprintf("%x is invalid magic cookie (should be %x)", cookie, magicCookie)
+		msg := strconv.FormatUint(uint64(cookie), 16) + " is invalid magic cookie (should be " +
+			strconv.FormatUint(magicCookie, 16) + ")"
 
 		return newDecodeErr("message", "cookie", msg)
 	}
 	if len(buf) < fullSize {
-		msg := fmt.Sprintf("buffer length %d is less than %d (expected message size)", len(buf), fullSize)
+		msg := "buffer length " + strconv.Itoa(len(buf)) + " is less than " +
+			strconv.Itoa(fullSize) + " (expected message size)"
 
 		return newAttrDecodeErr("message", msg)
 	}
@@ -394,6 +679,7 @@ func (m *Message) Decode() error {
 	copy(m.TransactionID[:], buf[8:messageHeaderSize])
 
 	m.Attributes = m.Attributes[:0]
+	m.lookup.reset()
 	var (
 		offset = 0
 		b      = buf[messageHeaderSize:fullSize]
@@ -401,7 +687,8 @@ func (m *Message) Decode() error {
 	for offset < size {
 		// checking that we have enough bytes to read header
 		if len(b) < attributeHeaderSize {
-			msg := fmt.Sprintf("buffer length %d is less than %d (expected header size)", len(b), attributeHeaderSize)
+			msg := "buffer length " + strconv.Itoa(len(b)) + " is less than " +
+				strconv.Itoa(attributeHeaderSize) + " (expected header size)"
 
 			return newAttrDecodeErr("header", msg)
 		}
@@ -409,6 +696,7 @@ func (m *Message) Decode() error {
 			attr = RawAttribute{
 				Type:   compatAttrType(bin.Uint16(b[0:2])), // first 2 bytes
 				Length: bin.Uint16(b[2:4]),                 // second 2 bytes
+				Offset: messageHeaderSize + offset,
 			}
 			aL     = int(attr.Length)             // attribute length
 			aBuffL = nearestPaddedValueLength(aL) // expected buffer length (with padding)
@@ -416,7 +704,8 @@ func (m *Message) Decode() error {
 		b = b[attributeHeaderSize:] // slicing again to simplify value read
 		offset += attributeHeaderSize
 		if len(b) < aBuffL { // checking size
-			msg := fmt.Sprintf("buffer length %d is less than %d (expected value size for %s)", len(b), aBuffL, attr.Type)
+			msg := "buffer length " + strconv.Itoa(len(b)) + " is less than " +
+				strconv.Itoa(aBuffL) + " (expected value size for " + attr.Type.String() + ")"
 
 			return newAttrDecodeErr("value", msg)
 		}
@@ -503,6 +792,14 @@ const (
 	MethodConnectionAttempt Method = 0x000c
 )
 
+// MethodNames returns registry of known method names,
+// e.g. "Binding" for MethodBinding.
+//
+// Returned map is a copy, so modifying it does not affect Method.String.
+func MethodNames() map[Method]string {
+	return methodName()
+}
+
 func methodName() map[Method]string {
 	return map[Method]string{
 		MethodBinding:          "Binding",
@@ -524,7 +821,7 @@ func (m Method) String() string {
 	s, ok := methodName()[m]
 	if !ok {
 		// Falling back to hex representation.
-		s = fmt.Sprintf("0x%x", uint16(m))
+		s = "0x" + strconv.FormatUint(uint64(m), 16)
 	}
 
 	return s
@@ -621,7 +918,7 @@ func (t *MessageType) ReadValue(v uint16) {
 }
 
 func (t MessageType) String() string {
-	return fmt.Sprintf("%s %s", t.Method, t.Class)
+	return t.Method.String() + " " + t.Class.String()
 }
 
 // Contains return true if message contain t attribute.
@@ -10,14 +10,10 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
-	"encoding/csv"
 	"errors"
 	"fmt"
-	"hash/crc64"
 	"io"
 	"net"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -149,6 +145,214 @@ func TestMessage_WriteTo(t *testing.T) {
 	}
 }
 
+func TestMessage_WriteBuffersTo(t *testing.T) {
+	msg := MustBuild(TransactionID, NewType(MethodSend, ClassIndication), NewSoftware("software"))
+	data := []byte{1, 2, 3, 4, 5, 6, 7}
+	expected := MustBuild(msg, msg.Type, NewSoftware("software"), RawAttribute{Type: AttrData, Value: data})
+	t.Run("Buffer", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		n, err := msg.WriteBuffersTo(buf, AttrData, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(expected.Raw)) {
+			t.Errorf("n = %d, expected %d", n, len(expected.Raw))
+		}
+		if !bytes.Equal(buf.Bytes(), expected.Raw) {
+			t.Errorf("%x != %x", buf.Bytes(), expected.Raw)
+		}
+	})
+	t.Run("UDP", func(t *testing.T) {
+		server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if closeErr := server.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		client, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr)) //nolint:forcetypeassert
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if closeErr := client.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+		}()
+		if !supportsVectoredWrite(client) {
+			t.Fatal("should support vectored write")
+		}
+		if _, err = msg.WriteBuffersTo(client, AttrData, data); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1024)
+		n, err := server.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], expected.Raw) {
+			t.Errorf("%x != %x", buf[:n], expected.Raw)
+		}
+	})
+	t.Run("Trailing", func(t *testing.T) {
+		withFingerprint := MustBuild(TransactionID, BindingRequest, Fingerprint)
+		if _, err := withFingerprint.WriteBuffersTo(io.Discard, AttrData, data); !errors.Is(err, ErrTrailingAttributes) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Overflow", func(t *testing.T) {
+		if _, err := msg.WriteBuffersTo(io.Discard, AttrData, make([]byte, 0xFFFF)); !IsAttrSizeOverflow(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func BenchmarkMessage_WriteBuffersTo(b *testing.B) {
+	msg := MustBuild(TransactionID, NewType(MethodSend, ClassIndication))
+	data := make([]byte, 1200)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := msg.WriteBuffersTo(io.Discard, AttrData, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRawAttribute_Offset(t *testing.T) {
+	msg := MustBuild(TransactionID, BindingRequest,
+		NewSoftware("abc"),
+		NewUsername("user"),
+		NewShortTermIntegrity("pwd"),
+		Fingerprint,
+	)
+	decoded := new(Message)
+	if err := Decode(msg.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*Message{msg, decoded} {
+		expected := messageHeaderSize
+		for _, a := range m.Attributes {
+			if a.Offset != expected {
+				t.Errorf("%s: offset %d, expected %d", a.Type, a.Offset, expected)
+			}
+			if got := AttrType(bin.Uint16(m.Raw[a.Offset:])); got != a.Type {
+				t.Errorf("%s: got type %s at offset", a.Type, got)
+			}
+			expected += attributeHeaderSize + nearestPaddedValueLength(int(a.Length))
+		}
+	}
+}
+
+func TestMessage_Replace(t *testing.T) {
+	for _, username := range []string{"u", "user", "longer username", ""} {
+		msg := MustBuild(TransactionID, BindingRequest,
+			NewSoftware("abc"),
+			NewUsername("bob"),
+			NewRealm("realm"),
+		)
+		if err := msg.Replace(AttrUsername, []byte(username)); err != nil {
+			t.Fatal(err)
+		}
+		expected := MustBuild(NewTransactionIDSetter(msg.TransactionID), BindingRequest,
+			NewSoftware("abc"),
+			NewUsername(username),
+			NewRealm("realm"),
+		)
+		if !bytes.Equal(msg.Raw, expected.Raw) {
+			t.Errorf("%q: raw %x, expected %x", username, msg.Raw, expected.Raw)
+		}
+		if !msg.Equal(expected) {
+			t.Errorf("%q: %s not equal to %s", username, msg, expected)
+		}
+		for i, a := range msg.Attributes {
+			if a.Offset != expected.Attributes[i].Offset {
+				t.Errorf("%q: %s offset %d, expected %d", username, a.Type, a.Offset, expected.Attributes[i].Offset)
+			}
+		}
+	}
+	t.Run("NotFound", func(t *testing.T) {
+		msg := MustBuild(TransactionID, BindingRequest)
+		if err := msg.Replace(AttrUsername, []byte("user")); !errors.Is(err, ErrAttributeNotFound) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Overflow", func(t *testing.T) {
+		msg := MustBuild(TransactionID, BindingRequest, NewUsername("user"))
+		if err := msg.Replace(AttrUsername, make([]byte, 0xFFFF+1)); !IsAttrSizeOverflow(err) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestMessage_Del(t *testing.T) {
+	msg := MustBuild(TransactionID, BindingRequest,
+		NewSoftware("abc"),
+		NewUsername("user"),
+		NewRealm("realm"),
+	)
+	if msg.Del(AttrNonce) {
+		t.Error("deleted missing attribute")
+	}
+	for _, tc := range []struct {
+		attr     AttrType
+		expected []Setter
+	}{
+		{AttrUsername, []Setter{NewSoftware("abc"), NewRealm("realm")}},
+		{AttrSoftware, []Setter{NewRealm("realm")}},
+		{AttrRealm, nil},
+	} {
+		if !msg.Del(tc.attr) {
+			t.Fatalf("%s not deleted", tc.attr)
+		}
+		setters := append([]Setter{NewTransactionIDSetter(msg.TransactionID), BindingRequest}, tc.expected...)
+		expected := MustBuild(setters...)
+		if !bytes.Equal(msg.Raw, expected.Raw) {
+			t.Errorf("%s: raw %x, expected %x", tc.attr, msg.Raw, expected.Raw)
+		}
+		for i, a := range msg.Attributes {
+			if a.Offset != expected.Attributes[i].Offset {
+				t.Errorf("%s: %s offset %d, expected %d", tc.attr, a.Type, a.Offset, expected.Attributes[i].Offset)
+			}
+		}
+	}
+}
+
+func TestMessage_EncodeCanonical(t *testing.T) {
+	id := NewTransactionIDSetter(NewTransactionID())
+	expected := MustBuild(id, BindingRequest,
+		NewUsername("user"),
+		RawAttribute{Type: AttrData, Value: []byte{1}},
+		RawAttribute{Type: AttrData, Value: []byte{2, 3}},
+		NewSoftware("software"),
+		RawAttribute{Type: AttrMessageIntegrity, Value: []byte{4}},
+		RawAttribute{Type: AttrMessageIntegritySHA256, Value: []byte{5}},
+		RawAttribute{Type: AttrFingerprint, Value: []byte{6}},
+	)
+	msg := MustBuild(id, BindingRequest,
+		RawAttribute{Type: AttrFingerprint, Value: []byte{6}},
+		RawAttribute{Type: AttrData, Value: []byte{1}},
+		NewSoftware("software"),
+		RawAttribute{Type: AttrMessageIntegritySHA256, Value: []byte{5}},
+		RawAttribute{Type: AttrMessageIntegrity, Value: []byte{4}},
+		RawAttribute{Type: AttrData, Value: []byte{2, 3}},
+		NewUsername("user"),
+	)
+	msg.EncodeCanonical()
+	if !bytes.Equal(msg.Raw, expected.Raw) {
+		t.Errorf("raw %x, expected %x", msg.Raw, expected.Raw)
+	}
+	decoded := new(Message)
+	if err := Decode(msg.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(msg) {
+		t.Errorf("%s not equal to %s", decoded, msg)
+	}
+}
+
 func TestMessage_Cookie(t *testing.T) {
 	buf := make([]byte, 20)
 	mDecoded := New()
@@ -504,6 +708,30 @@ func TestMessage_String(t *testing.T) {
 	}
 }
 
+func TestTransactionIDString(t *testing.T) {
+	id := [TransactionIDSize]byte{
+		0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+	}
+	s := TransactionIDString(id)
+	if s != "t+enAbw01ob6h9+u" {
+		t.Errorf("unexpected string %q", s)
+	}
+	for _, in := range []string{s, "b7e7a701bc34d686fa87dfae", "B7E7A701BC34D686FA87DFAE"} {
+		parsed, err := ParseTransactionID(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+		}
+		if parsed != id {
+			t.Errorf("%q: parsed %x", in, parsed)
+		}
+	}
+	for _, in := range []string{"", "t+enAbw01ob6h9+", "t+enAbw01ob6h9+!", "b7e7a701bc34d686fa87dfaz"} {
+		if _, err := ParseTransactionID(in); !errors.Is(err, ErrInvalidTransactionID) {
+			t.Errorf("%q: unexpected error %v", in, err)
+		}
+	}
+}
+
 func TestIsMessage(t *testing.T) {
 	m := New()
 	NewSoftware("software").AddTo(m) //nolint:errcheck,gosec
@@ -548,25 +776,54 @@ func BenchmarkIsMessage(b *testing.B) {
 	}
 }
 
-func loadData(tb testing.TB, name string) []byte {
-	tb.Helper()
-
-	name = filepath.Join("testdata", name)
-	f, err := os.Open(name) //nolint:gosec
-	if err != nil {
-		tb.Fatal(err)
-	}
-	defer func() {
-		if errClose := f.Close(); errClose != nil {
-			tb.Fatal(errClose)
+func TestIsMessageStrict(t *testing.T) {
+	clone := func(m *Message) []byte {
+		return append([]byte{}, m.Raw...)
+	}
+	withFingerprint := MustBuild(TransactionID, BindingRequest, NewSoftware("software"), Fingerprint)
+	badFingerprint := clone(withFingerprint)
+	badFingerprint[len(badFingerprint)-1]++
+	afterFingerprint := MustBuild(TransactionID, BindingRequest, Fingerprint, NewSoftware("software"))
+	plain := MustBuild(TransactionID, BindingRequest, NewSoftware("software"))
+	badLength := clone(plain)
+	bin.PutUint16(badLength[2:4], uint16(len(badLength))) //nolint:gosec // G115
+	badAttrLength := clone(plain)
+	bin.PutUint16(badAttrLength[messageHeaderSize+2:], 0xff)
+	badType := clone(plain)
+	badType[0] |= 0x80
+
+	for _, tc := range []struct {
+		name string
+		in   []byte
+		out  bool
+	}{
+		{"Nil", nil, false},
+		{"Header", MustBuild(BindingRequest).Raw, true},
+		{"Plain", plain.Raw, true},
+		{"Fingerprint", withFingerprint.Raw, true},
+		{"BadFingerprint", badFingerprint, false},
+		{"AfterFingerprint", afterFingerprint.Raw, false},
+		{"Truncated", plain.Raw[:len(plain.Raw)-1], false},
+		{"BadLength", badLength, false},
+		{"BadAttributeLength", badAttrLength, false},
+		{"BadType", badType, false},
+	} {
+		if got := IsMessageStrict(tc.in); got != tc.out {
+			t.Errorf("%s: IsMessageStrict() %v != %v", tc.name, got, tc.out)
 		}
-	}()
-	v, err := io.ReadAll(f)
-	if err != nil {
-		tb.Fatal(err)
 	}
+}
+
+func BenchmarkIsMessageStrict(b *testing.B) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("cydev/stun test"), Fingerprint)
+	b.SetBytes(int64(len(m.Raw)))
+	b.ReportAllocs()
 
-	return v
+	for i := 0; i < b.N; i++ {
+		if !IsMessageStrict(m.Raw) {
+			b.Fatal("Should be message")
+		}
+	}
 }
 
 func TestExampleChrome(t *testing.T) {
@@ -579,36 +836,10 @@ func TestExampleChrome(t *testing.T) {
 }
 
 func TestMessageFromBrowsers(t *testing.T) {
-	// file contains udp-packets captured from browsers (WebRTC)
-	reader := csv.NewReader(bytes.NewReader(loadData(t, "frombrowsers.csv")))
-	reader.Comment = '#'
-	_, err := reader.Read() // skipping header
-	if err != nil {
-		t.Fatal("failed to skip header of csv: ", err)
-	}
-	crcTable := crc64.MakeTable(crc64.ISO)
 	msg := New()
-	for {
-		line, err := reader.Read()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			t.Fatal("failed to read csv line: ", err)
-		}
-		data, err := base64.StdEncoding.DecodeString(line[1])
-		if err != nil {
-			t.Fatal("failed to decode ", line[1], " as base64: ", err)
-		}
-		b, err := strconv.ParseUint(line[2], 10, 64)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if b != crc64.Checksum(data, crcTable) {
-			t.Error("crc64 check failed for ", line[1])
-		}
-		if _, err = msg.Write(data); err != nil {
-			t.Error("failed to decode ", line[1], " as message: ", err)
+	for _, data := range loadBrowserMessages(t) {
+		if _, err := msg.Write(data); err != nil {
+			t.Error("failed to decode ", base64.StdEncoding.EncodeToString(data), " as message: ", err)
 		}
 		msg.Reset()
 	}
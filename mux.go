@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"net"
+	"sync"
+)
+
+// MessageHandler handles message m received from addr.
+//
+// The m is valid only until MessageHandler returns, use m.CloneTo to
+// retain it.
+type MessageHandler func(m *Message, addr net.Addr)
+
+// muxReadBufferSize is size of buffer that Mux reads packets into.
+const muxReadBufferSize = 1500
+
+// Mux dispatches STUN messages to handlers registered by message type,
+// i.e. (Method, Class) pair. Messages without registered handler are
+// passed to default handler if any, otherwise discarded.
+//
+// Mux is useful for endpoints that act both as STUN client and server
+// on single socket, like ICE full agents.
+type Mux struct {
+	mux      sync.RWMutex
+	handlers map[MessageType]MessageHandler
+	fallback MessageHandler
+}
+
+// NewMux initializes and returns new Mux without handlers.
+func NewMux() *Mux {
+	return &Mux{
+		handlers: make(map[MessageType]MessageHandler),
+	}
+}
+
+// Handle registers h for messages of type t, replacing the previous one.
+// If h is nil, handler for t is removed.
+func (x *Mux) Handle(t MessageType, h MessageHandler) {
+	x.mux.Lock()
+	if h == nil {
+		delete(x.handlers, t)
+	} else {
+		x.handlers[t] = h
+	}
+	x.mux.Unlock()
+}
+
+// HandleDefault sets h as handler for messages that have no registered
+// handler. If h is nil, such messages are discarded.
+func (x *Mux) HandleDefault(h MessageHandler) {
+	x.mux.Lock()
+	x.fallback = h
+	x.mux.Unlock()
+}
+
+// Dispatch passes m to handler registered for m.Type or to the default
+// handler. Returns false if m was discarded.
+func (x *Mux) Dispatch(m *Message, addr net.Addr) bool {
+	x.mux.RLock()
+	h, ok := x.handlers[m.Type]
+	if !ok {
+		h = x.fallback
+	}
+	x.mux.RUnlock()
+	if h == nil {
+		return false
+	}
+	h(m, addr)
+
+	return true
+}
+
+// Serve reads packets from conn and dispatches decoded STUN messages
+// until read error, which is returned. Packets that are not valid STUN
+// messages are ignored. Handlers are called sequentially from the
+// calling goroutine.
+func (x *Mux) Serve(conn net.PacketConn) error {
+	m := new(Message)
+	buf := make([]byte, muxReadBufferSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if !IsMessage(buf[:n]) {
+			continue
+		}
+		m.Raw = buf[:n]
+		if err := m.Decode(); err != nil {
+			continue
+		}
+		x.Dispatch(m, addr)
+	}
+}
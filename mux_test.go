@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMux_Dispatch(t *testing.T) {
+	var got []string
+	record := func(name string) MessageHandler {
+		return func(*Message, net.Addr) {
+			got = append(got, name)
+		}
+	}
+	x := NewMux()
+	x.Handle(BindingRequest, record("request"))
+	x.Handle(BindingSuccess, record("success"))
+	if x.Dispatch(MustBuild(BindingError), nil) {
+		t.Error("should discard message without handler")
+	}
+	x.HandleDefault(record("default"))
+	for _, m := range []*Message{
+		MustBuild(BindingRequest),
+		MustBuild(BindingSuccess),
+		MustBuild(BindingError),
+	} {
+		if !x.Dispatch(m, nil) {
+			t.Errorf("%s discarded", m.Type)
+		}
+	}
+	x.Handle(BindingSuccess, nil)
+	x.Dispatch(MustBuild(BindingSuccess), nil)
+	expected := []string{"request", "success", "default", "default"}
+	if len(got) != len(expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("[%d]: got %s, expected %s", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestMux_Serve(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := peer.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	received := make(chan MessageType, 1)
+	x := NewMux()
+	x.Handle(BindingRequest, func(m *Message, addr net.Addr) {
+		if addr.String() != peer.LocalAddr().String() {
+			t.Errorf("unexpected addr %s", addr)
+		}
+		received <- m.Type
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- x.Serve(conn)
+	}()
+	for _, b := range [][]byte{
+		[]byte("not a STUN message"),
+		MustBuild(TransactionID, BindingRequest).Raw,
+	} {
+		if _, err = peer.WriteTo(b, conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case typ := <-received:
+		if typ != BindingRequest {
+			t.Errorf("unexpected type %s", typ)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	if err = conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-served; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "net"
+
+// MappingObservation is result of binding request, see DetectNAT64.
+type MappingObservation struct {
+	LocalAddr  net.Addr // local address of socket, e.g. Client.LocalAddr
+	RemoteAddr net.Addr // STUN server address, e.g. Client.RemoteAddr
+	Mapped     XORMappedAddress
+}
+
+// NAT64Info describes IPv6-to-IPv4 translation detected by DetectNAT64.
+type NAT64Info struct {
+	// NAT64 is true if request from IPv6 socket was mapped to IPv4 address.
+	NAT64 bool
+	// CLAT is true if IPv4 socket is bound to address from 192.0.0.0/29,
+	// reserved for 464XLAT customer-side translator (RFC 7335).
+	CLAT bool
+	// Prefix is /96 prefix used to synthesize IPv6 addresses (RFC 6052),
+	// nil if not observable.
+	Prefix *net.IPNet
+}
+
+// Detected reports whether any translation was detected.
+func (i NAT64Info) Detected() bool {
+	return i.NAT64 || i.CLAT
+}
+
+// nat64WellKnownPrefix is 64:ff9b::/96, RFC 6052 Section 2.1.
+var nat64WellKnownPrefix = &net.IPNet{ //nolint:gochecknoglobals
+	IP:   net.IP{0x00, 0x64, 0xff, 0x9b, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	Mask: net.CIDRMask(96, 128),
+}
+
+// clatNetwork is 192.0.0.0/29, RFC 7335.
+var clatNetwork = &net.IPNet{ //nolint:gochecknoglobals
+	IP:   net.IPv4(192, 0, 0, 0).To4(),
+	Mask: net.CIDRMask(29, 32),
+}
+
+// DetectNAT64 detects NAT64 and 464XLAT by comparing address family of local
+// socket with family of XOR-MAPPED-ADDRESS for binding requests performed
+// over IPv4 and IPv6, e.g. to the same dual-stack or IPv4-only server.
+//
+// The synthesized prefix is reported if IPv6 server address is in the
+// well-known prefix 64:ff9b::/96 or embeds IPv4 address of server from
+// another observation. Only /96 prefixes are detected.
+func DetectNAT64(observations ...MappingObservation) NAT64Info {
+	var info NAT64Info
+	for _, o := range observations {
+		local := addrIP(o.LocalAddr)
+		if local == nil {
+			continue
+		}
+		if local.To4() != nil {
+			if clatNetwork.Contains(local) {
+				info.CLAT = true
+			}
+
+			continue
+		}
+		if o.Mapped.IP.To4() != nil {
+			info.NAT64 = true
+		}
+		if info.Prefix == nil {
+			info.Prefix = synthesizedPrefix(addrIP(o.RemoteAddr), observations)
+		}
+	}
+
+	return info
+}
+
+// synthesizedPrefix returns /96 prefix of IPv6 address remote if it is
+// in the well-known prefix or embeds IPv4 remote address of observations.
+func synthesizedPrefix(remote net.IP, observations []MappingObservation) *net.IPNet {
+	if remote == nil || remote.To4() != nil {
+		return nil
+	}
+	prefix := &net.IPNet{
+		IP:   remote.Mask(nat64WellKnownPrefix.Mask),
+		Mask: nat64WellKnownPrefix.Mask,
+	}
+	if nat64WellKnownPrefix.Contains(remote) {
+		return prefix
+	}
+	embedded := remote[net.IPv6len-net.IPv4len:]
+	for _, o := range observations {
+		if ip := addrIP(o.RemoteAddr).To4(); ip != nil && ip.Equal(embedded) {
+			return prefix
+		}
+	}
+
+	return nil
+}
+
+// addrIP returns IP of UDP or TCP address, nil otherwise.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	default:
+		return nil
+	}
+}
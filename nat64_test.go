@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDetectNAT64(t *testing.T) {
+	var (
+		server4 = &net.UDPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 3478}
+		public4 = XORMappedAddress{IP: net.IPv4(203, 0, 113, 7), Port: 50000}
+		public6 = XORMappedAddress{IP: net.ParseIP("2001:db8::7"), Port: 50000}
+		local4  = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 40000}
+		clat4   = &net.UDPAddr{IP: net.IPv4(192, 0, 0, 2), Port: 40000}
+		local6  = &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 40000}
+	)
+	for _, tc := range []struct {
+		name         string
+		observations []MappingObservation
+		nat64, clat  bool
+		prefix       string
+	}{
+		{
+			name: "DualStack",
+			observations: []MappingObservation{
+				{LocalAddr: local4, RemoteAddr: server4, Mapped: public4},
+				{LocalAddr: local6, RemoteAddr: &net.UDPAddr{IP: net.ParseIP("2001:db8:1::1")}, Mapped: public6},
+			},
+		},
+		{
+			name: "WellKnownPrefix",
+			observations: []MappingObservation{
+				{LocalAddr: local6, RemoteAddr: &net.UDPAddr{IP: net.ParseIP("64:ff9b::c633:6401")}, Mapped: public4},
+			},
+			nat64:  true,
+			prefix: "64:ff9b::/96",
+		},
+		{
+			name: "NetworkSpecificPrefix",
+			observations: []MappingObservation{
+				{LocalAddr: clat4, RemoteAddr: server4, Mapped: public4},
+				{LocalAddr: local6, RemoteAddr: &net.TCPAddr{IP: net.ParseIP("2001:db8:64::c633:6401")}, Mapped: public4},
+			},
+			nat64:  true,
+			clat:   true,
+			prefix: "2001:db8:64::/96",
+		},
+		{
+			name: "UnknownPrefix",
+			observations: []MappingObservation{
+				{LocalAddr: local6, RemoteAddr: &net.UDPAddr{IP: net.ParseIP("2001:db8:64::c633:6402")}, Mapped: public4},
+				{LocalAddr: nil, RemoteAddr: server4},
+			},
+			nat64: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info := DetectNAT64(tc.observations...)
+			if info.NAT64 != tc.nat64 || info.CLAT != tc.clat {
+				t.Errorf("unexpected %+v", info)
+			}
+			if info.Detected() != (tc.nat64 || tc.clat) {
+				t.Error("unexpected Detected")
+			}
+			prefix := ""
+			if info.Prefix != nil {
+				prefix = info.Prefix.String()
+			}
+			if prefix != tc.prefix {
+				t.Errorf("prefix %q, expected %q", prefix, tc.prefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrAttributeAfterFingerprint means that message contains attribute
+	// after FINGERPRINT, which must be the last one.
+	ErrAttributeAfterFingerprint = errors.New("attribute after FINGERPRINT")
+	// ErrDuplicateIntegrity means that message contains more than one
+	// MESSAGE-INTEGRITY attribute.
+	ErrDuplicateIntegrity = errors.New("duplicate MESSAGE-INTEGRITY attribute")
+)
+
+type strictOrderChecker struct{}
+
+// StrictOrder is Checker that rejects messages with any attribute after
+// FINGERPRINT or with second MESSAGE-INTEGRITY attribute, returning
+// ErrAttributeAfterFingerprint or ErrDuplicateIntegrity.
+//
+// Decode accepts such messages, so servers can use StrictOrder to reject
+// malformed or tampered messages before further processing.
+//
+// Example:
+//
+//	if err := m.Check(StrictOrder, Fingerprint); err != nil {
+//		// Rejecting message.
+//	}
+//
+// RFC 5389 Section 15.4 and Section 15.5.
+var StrictOrder Checker = strictOrderChecker{} //nolint:gochecknoglobals
+
+func (strictOrderChecker) Check(m *Message) error {
+	var fingerprint, integrity bool
+	for _, a := range m.Attributes {
+		if fingerprint {
+			return fmt.Errorf("%w: %s", ErrAttributeAfterFingerprint, a.Type)
+		}
+		switch a.Type {
+		case AttrFingerprint:
+			fingerprint = true
+		case AttrMessageIntegrity:
+			if integrity {
+				return ErrDuplicateIntegrity
+			}
+			integrity = true
+		default:
+		}
+	}
+
+	return nil
+}
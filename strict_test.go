@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStrictOrder(t *testing.T) {
+	integrity := NewShortTermIntegrity("pwd")
+	for _, tc := range []struct {
+		name    string
+		setters []Setter
+		err     error
+	}{
+		{"Empty", []Setter{BindingRequest}, nil},
+		{"Valid", []Setter{BindingRequest, NewSoftware("s"), integrity, Fingerprint}, nil},
+		{"AfterFingerprint", []Setter{BindingRequest, Fingerprint, NewSoftware("s")}, ErrAttributeAfterFingerprint},
+		{"DuplicateFingerprint", []Setter{BindingRequest, Fingerprint, Fingerprint}, ErrAttributeAfterFingerprint},
+		{"DuplicateIntegrity", []Setter{
+			BindingRequest,
+			RawAttribute{Type: AttrMessageIntegrity, Value: make([]byte, messageIntegritySize)},
+			RawAttribute{Type: AttrMessageIntegrity, Value: make([]byte, messageIntegritySize)},
+		}, ErrDuplicateIntegrity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := MustBuild(tc.setters...)
+			decoded := new(Message)
+			if err := Decode(m.Raw, decoded); err != nil {
+				t.Fatal(err)
+			}
+			if err := decoded.Check(StrictOrder); !errors.Is(err, tc.err) {
+				t.Errorf("unexpected error: %v, expected %v", err, tc.err)
+			}
+		})
+	}
+}
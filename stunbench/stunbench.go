@@ -0,0 +1,449 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package stunbench implements STUN load generation, allowing to embed
+// load tests into soak or integration test suites.
+package stunbench
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/bits"
+	mathRand "math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+var (
+	// ErrNoDial means that Config.Dial is not set.
+	ErrNoDial = errors.New("no dial function provided")
+	// ErrNoRate means that Config.OpenLoop is set without Config.Rate.
+	ErrNoRate = errors.New("open loop requires rate")
+	// ErrRateTooHigh means that Config.Rate exceeds one request per
+	// nanosecond.
+	ErrRateTooHigh = errors.New("rate is too high")
+)
+
+// Config configures load generation.
+type Config struct {
+	// Dial returns new client for worker. Required.
+	Dial func() (*stun.Client, error)
+	// Workers is number of concurrent workers, each using its own client.
+	// Client with failed connection (e.g. reset TCP or TLS stream) is
+	// replaced by new one from Dial. Defaults to runtime.GOMAXPROCS(0).
+	Workers int
+	// Rate limits total number of requests per second, zero means no limit.
+	// Must not exceed one request per nanosecond.
+	Rate int
+	// OpenLoop starts requests at Rate regardless of responses, spreading
+	// them over clients round-robin, so latency includes queueing on
+	// server. Otherwise each worker waits for response before next request,
+	// which hides queueing. Requires Rate.
+	OpenLoop bool
+	// CryptoRand enables crypto/rand as random source for transaction id.
+	CryptoRand bool
+	// NoRetransmit disables retransmissions via stun.NoRetransmitOpt, which
+	// should be set for reliable transports, e.g. TCP and TLS.
+	NoRetransmit bool
+	// OnError is called on failed transactions except timeouts, if set.
+	OnError func(error)
+}
+
+// Stats are aggregated results of Run.
+type Stats struct {
+	Requests  uint64 // started transactions
+	Succeeded uint64 // transactions with response
+	Failed    uint64 // timed out or failed transactions
+	Redials   uint64 // clients replaced after connection failure
+	Elapsed   time.Duration
+	Latency   Latency // of succeeded transactions
+}
+
+// Latency is distribution of transaction round-trip times, see
+// stun.Event.RTT.
+type Latency struct {
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// RPS returns number of succeeded transactions per second.
+func (s Stats) RPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(s.Succeeded) / s.Elapsed.Seconds()
+}
+
+type stats struct {
+	requests  atomic.Uint64
+	succeeded atomic.Uint64
+	failed    atomic.Uint64
+	redials   atomic.Uint64
+
+	mux       sync.Mutex
+	latencies histogram
+}
+
+// succeed counts succeeded transaction with rtt.
+func (s *stats) succeed(rtt time.Duration) {
+	s.succeeded.Add(1)
+	s.mux.Lock()
+	s.latencies.record(rtt)
+	s.mux.Unlock()
+}
+
+// latency returns distribution of recorded rtt values.
+func (s *stats) latency() Latency {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.latencies.latency()
+}
+
+const (
+	// histogramSubBits is log2 of number of buckets per power of two, so
+	// percentiles have relative error below 1/32.
+	histogramSubBits    = 5
+	histogramSubBuckets = 1 << histogramSubBits
+	// histogramBuckets covers all positive time.Duration values.
+	histogramBuckets = (64 - histogramSubBits) * histogramSubBuckets
+)
+
+// histogram is log-linear histogram of durations, like HdrHistogram, so
+// memory does not grow with number of recorded values.
+type histogram struct {
+	buckets [histogramBuckets]uint64
+	count   uint64
+	sum     time.Duration
+	max     time.Duration
+}
+
+// histogramBucket returns index of bucket for d.
+func histogramBucket(d time.Duration) int {
+	v := uint64(d) //nolint:gosec // G115, d is not negative
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - histogramSubBits - 1
+
+	return (shift+1)*histogramSubBuckets + int(v>>shift) - histogramSubBuckets
+}
+
+// histogramValue returns highest duration in bucket with index i.
+func histogramValue(i int) time.Duration {
+	if i < histogramSubBuckets {
+		return time.Duration(i)
+	}
+	shift := i/histogramSubBuckets - 1
+	v := uint64(i%histogramSubBuckets+histogramSubBuckets) << shift
+
+	return time.Duration(v + 1<<shift - 1) //nolint:gosec // G115
+}
+
+func (h *histogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.buckets[histogramBucket(d)]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// latency returns distribution of recorded values, percentiles are
+// highest values of their buckets, but not greater than Max.
+func (h *histogram) latency() Latency {
+	if h.count == 0 {
+		return Latency{}
+	}
+	percentile := func(p uint64) time.Duration {
+		var (
+			rank  = (h.count - 1) * p / 100
+			total uint64
+		)
+		for i, n := range h.buckets {
+			if total += n; total > rank {
+				if v := histogramValue(i); v < h.max {
+					return v
+				}
+
+				break
+			}
+		}
+
+		return h.max
+	}
+
+	return Latency{
+		Mean: h.sum / time.Duration(h.count), //nolint:gosec // G115
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P99:  percentile(99),
+		Max:  h.max,
+	}
+}
+
+// Run sends binding requests with cfg.Workers concurrent workers until
+// ctx is done, returning aggregated stats. Clients are closed on return,
+// close errors are returned along with stats.
+func Run(ctx context.Context, cfg Config) (Stats, error) {
+	if cfg.Dial == nil {
+		return Stats{}, ErrNoDial
+	}
+	if cfg.OpenLoop && cfg.Rate <= 0 {
+		return Stats{}, ErrNoRate
+	}
+	if cfg.Rate > int(time.Second) {
+		return Stats{}, ErrRateTooHigh
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	clients := make([]*stun.Client, 0, workers)
+	closeClients := func() error {
+		var errs []error
+		for _, c := range clients {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+	for i := 0; i < workers; i++ {
+		c, err := cfg.Dial()
+		if err != nil {
+			return Stats{}, errors.Join(err, closeClients())
+		}
+		clients = append(clients, c)
+	}
+	var (
+		s      stats
+		wg     sync.WaitGroup
+		tokens <-chan time.Time
+		start  = time.Now()
+	)
+	if cfg.Rate > 0 && !cfg.OpenLoop {
+		ticker := time.NewTicker(time.Second / time.Duration(cfg.Rate))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+	if cfg.OpenLoop {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg.generate(ctx, clients, start, &s)
+		}()
+	} else {
+		for i := range clients {
+			wg.Add(1)
+			go func(c **stun.Client) {
+				defer wg.Done()
+				cfg.work(ctx, c, tokens, &s)
+			}(&clients[i])
+		}
+	}
+	<-ctx.Done()
+	result := Stats{
+		Requests:  s.requests.Load(),
+		Succeeded: s.succeeded.Load(),
+		Failed:    s.failed.Load(),
+		Redials:   s.redials.Load(),
+		Elapsed:   time.Since(start),
+		Latency:   s.latency(),
+	}
+	// Workers return on ctx done and do not replace clients after that,
+	// closing clients to stop pending transactions.
+	wg.Wait()
+	err := closeClients()
+
+	return result, err
+}
+
+// redial replaces *c with new client from cfg.Dial if connection of *c
+// has failed, returning false if worker should stop, e.g. if ctx is done
+// or Dial failed.
+func (cfg Config) redial(ctx context.Context, c **stun.Client, s *stats) bool {
+	if (*c).Connected() || ctx.Err() != nil {
+		return ctx.Err() == nil
+	}
+	client, err := cfg.Dial()
+	if err != nil {
+		cfg.onError(ctx, err)
+
+		return false
+	}
+	_ = (*c).Close()
+	*c = client
+	s.redials.Add(1)
+
+	return true
+}
+
+// openLoopInterval is maximum interval between request bursts of open
+// loop, so high rates are not limited by timer resolution.
+const openLoopInterval = time.Millisecond
+
+// generate starts requests at cfg.Rate via clients round-robin until ctx
+// is done, not waiting for responses.
+func (cfg Config) generate(ctx context.Context, clients []*stun.Client, start time.Time, s *stats) {
+	interval := time.Second / time.Duration(cfg.Rate)
+	if interval > openLoopInterval {
+		interval = openLoopInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var (
+		req     = stun.New()
+		started uint64
+		next    int
+	)
+	handler := func(event stun.Event) {
+		if event.Error == nil {
+			s.succeed(event.RTT)
+
+			return
+		}
+		if !errors.Is(event.Error, stun.ErrTransactionTimeOut) {
+			cfg.onError(ctx, event.Error)
+		}
+		s.failed.Add(1)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Starting all requests that are due, catching up after
+			// delays instead of lowering the rate.
+			due := uint64(now.Sub(start).Seconds() * float64(cfg.Rate))
+			for ; started < due; started++ {
+				c := &clients[next]
+				next = (next + 1) % len(clients)
+				if !cfg.redial(ctx, c, s) {
+					return
+				}
+				if err := cfg.newRequest(req); err != nil {
+					cfg.onError(ctx, err)
+
+					return
+				}
+				s.requests.Add(1)
+				if err := (*c).Start(req, handler, cfg.transactionOptions()...); err != nil {
+					if errors.Is(err, stun.ErrClientClosed) {
+						return
+					}
+					cfg.onError(ctx, err)
+					s.failed.Add(1)
+				}
+			}
+		}
+	}
+}
+
+// transactionOptions returns options for started transactions.
+func (cfg Config) transactionOptions() []stun.TransactionOption {
+	if cfg.NoRetransmit {
+		return []stun.TransactionOption{stun.NoRetransmitOpt}
+	}
+
+	return nil
+}
+
+// newRequest resets req to binding request with new transaction id.
+func (cfg Config) newRequest(req *stun.Message) error {
+	if cfg.CryptoRand {
+		if _, err := rand.Read(req.TransactionID[:]); err != nil {
+			return err
+		}
+	} else {
+		mathRand.Read(req.TransactionID[:]) //nolint:gosec
+	}
+	req.Type = stun.BindingRequest
+	req.WriteHeader()
+
+	return nil
+}
+
+// work sends requests via *c until ctx is done, waiting for token before
+// each request if tokens is not nil.
+func (cfg Config) work(ctx context.Context, clientPtr **stun.Client, tokens <-chan time.Time, s *stats) {
+	type result struct {
+		err error
+		rtt time.Duration
+	}
+	var (
+		req  = stun.New()
+		done = make(chan result, 1)
+	)
+	// Callbacks of transactions stopped by closing client are not called,
+	// so using Start instead of Do to not block after ctx is done.
+	handler := func(event stun.Event) {
+		done <- result{err: event.Error, rtt: event.RTT}
+	}
+	for {
+		if tokens != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tokens:
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+		if !cfg.redial(ctx, clientPtr, s) {
+			return
+		}
+		if err := cfg.newRequest(req); err != nil {
+			cfg.onError(ctx, err)
+
+			return
+		}
+		s.requests.Add(1)
+		if err := (*clientPtr).Start(req, handler, cfg.transactionOptions()...); err != nil {
+			if errors.Is(err, stun.ErrClientClosed) {
+				return
+			}
+			if !errors.Is(err, stun.ErrTransactionExists) {
+				cfg.onError(ctx, err)
+			}
+			s.failed.Add(1)
+
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case res := <-done:
+			if res.err == nil {
+				s.succeed(res.rtt)
+
+				continue
+			}
+			if !errors.Is(res.err, stun.ErrTransactionTimeOut) {
+				cfg.onError(ctx, res.err)
+			}
+			s.failed.Add(1)
+		}
+	}
+}
+
+// onError passes err to cfg.OnError, ignoring errors of transactions
+// stopped on shutdown.
+func (cfg Config) onError(ctx context.Context, err error) {
+	if cfg.OnError != nil && ctx.Err() == nil {
+		cfg.OnError(err)
+	}
+}
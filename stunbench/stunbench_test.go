@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunbench
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+	"github.com/pion/stun/v3/stuntest"
+)
+
+func TestRun(t *testing.T) {
+	addr, stop, err := stuntest.NewUDPServer(t, "udp4", 1500, func(req []byte) ([]byte, error) {
+		m := new(stun.Message)
+		if err := stun.Decode(req, m); err != nil {
+			return nil, err
+		}
+		res := stun.MustBuild(m, stun.BindingSuccess)
+
+		return res.Raw, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t)
+	t.Run("NoDial", func(t *testing.T) {
+		if _, err := Run(context.Background(), Config{}); !errors.Is(err, ErrNoDial) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	dial := func() (*stun.Client, error) {
+		return stun.Dial("udp4", addr.String())
+	}
+	t.Run("NoRate", func(t *testing.T) {
+		if _, err := Run(context.Background(), Config{Dial: dial, OpenLoop: true}); !errors.Is(err, ErrNoRate) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("RateTooHigh", func(t *testing.T) {
+		for _, openLoop := range []bool{false, true} {
+			cfg := Config{Dial: dial, Rate: int(time.Second) + 1, OpenLoop: openLoop}
+			if _, err := Run(context.Background(), cfg); !errors.Is(err, ErrRateTooHigh) {
+				t.Errorf("unexpected error %v (open loop: %v)", err, openLoop)
+			}
+		}
+	})
+	t.Run("OpenLoop", func(t *testing.T) {
+		const rate = 500
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		s, err := Run(ctx, Config{
+			Dial:     dial,
+			Workers:  2,
+			Rate:     rate,
+			OpenLoop: true,
+			OnError: func(err error) {
+				t.Errorf("unexpected error %v", err)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if maxRequests := uint64(s.Elapsed.Seconds()*rate) + 1; s.Requests == 0 || s.Requests > maxRequests {
+			t.Errorf("requests %d, expected at most %d", s.Requests, maxRequests)
+		}
+		if s.Succeeded == 0 || s.Latency.Max == 0 || s.Latency.P50 > s.Latency.Max {
+			t.Errorf("unexpected stats %+v", s)
+		}
+	})
+	for _, rate := range []int{0, 100} {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		s, err := Run(ctx, Config{
+			Dial:    dial,
+			Workers: 2,
+			Rate:    rate,
+			OnError: func(err error) {
+				t.Errorf("unexpected error %v", err)
+			},
+		})
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Succeeded == 0 || s.Requests < s.Succeeded || s.Latency.Mean == 0 {
+			t.Errorf("rate %d: unexpected stats %+v", rate, s)
+		}
+		if rate > 0 && s.RPS() > 2*float64(rate) {
+			t.Errorf("rate %d: RPS %f exceeds rate", rate, s.RPS())
+		}
+	}
+}
+
+func TestRun_Redial(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close() //nolint:errcheck
+	go func() {
+		// Responding to single request per connection.
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 1500)
+			if n, err := conn.Read(buf); err == nil {
+				m := new(stun.Message)
+				if err = stun.Decode(buf[:n], m); err == nil {
+					_, _ = conn.Write(stun.MustBuild(m, stun.BindingSuccess).Raw)
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	s, err := Run(ctx, Config{
+		Dial: func() (*stun.Client, error) {
+			conn, err := net.Dial("tcp4", l.Addr().String())
+			if err != nil {
+				return nil, err
+			}
+
+			return stun.NewClient(conn, stun.WithRTO(10*time.Millisecond))
+		},
+		Workers: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Redials == 0 || s.Succeeded < 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestRun_Stream(t *testing.T) {
+	server, err := stuntest.NewServer(t, "tcp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// Many outstanding transactions per client, so responses are coalesced.
+	s, err := Run(ctx, Config{
+		Dial: func() (*stun.Client, error) {
+			conn, err := net.Dial("tcp4", server.Addr().String())
+			if err != nil {
+				return nil, err
+			}
+
+			return stun.NewClient(conn, stun.WithStreamFraming())
+		},
+		Workers:      2,
+		Rate:         2000,
+		OpenLoop:     true,
+		NoRetransmit: true,
+		OnError: func(err error) {
+			t.Errorf("unexpected error %v", err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Succeeded == 0 || s.Failed != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if requests := server.Requests(); uint64(requests) > s.Requests {
+		t.Errorf("server got %d requests for %d transactions", requests, s.Requests)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	var h histogram
+	if l := h.latency(); l != (Latency{}) {
+		t.Errorf("unexpected latency %+v of empty histogram", l)
+	}
+	for i := 1; i <= 10000; i++ {
+		h.record(time.Duration(i) * time.Microsecond)
+	}
+	l := h.latency()
+	if l.Max != 10*time.Millisecond {
+		t.Errorf("unexpected max %s", l.Max)
+	}
+	if expected := 5000500 * time.Nanosecond; l.Mean != expected {
+		t.Errorf("unexpected mean %s, expected %s", l.Mean, expected)
+	}
+	for _, tc := range []struct {
+		got      time.Duration
+		expected time.Duration
+	}{
+		{l.P50, 5000 * time.Microsecond},
+		{l.P90, 9000 * time.Microsecond},
+		{l.P99, 9900 * time.Microsecond},
+	} {
+		if tc.got < tc.expected || tc.got > tc.expected+tc.expected/histogramSubBuckets {
+			t.Errorf("percentile %s, expected %s", tc.got, tc.expected)
+		}
+	}
+	t.Run("Bounds", func(t *testing.T) {
+		for _, d := range []time.Duration{0, 1, histogramSubBuckets, time.Second, math.MaxInt64} {
+			i := histogramBucket(d)
+			if i >= histogramBuckets || histogramValue(i) < d {
+				t.Errorf("%d: bucket %d with value %d", d, i, histogramValue(i))
+			}
+			if i > 0 && histogramValue(i-1) >= d {
+				t.Errorf("%d: previous bucket %d with value %d", d, i-1, histogramValue(i-1))
+			}
+		}
+	})
+}
@@ -0,0 +1,442 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package stunnat implements NAT behavior discovery tests of RFC 5780:
+//   - 4.3.  Determining NAT Mapping Behavior
+//   - 4.4.  Determining NAT Filtering Behavior
+package stunnat
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/logging"
+	"github.com/pion/stun/v3"
+)
+
+// Behavior is NAT mapping or filtering behavior, RFC 4787 Section 4.
+type Behavior string
+
+// Possible NAT mapping and filtering behaviors.
+const (
+	BehaviorInconclusive         Behavior = "inconclusive"
+	BehaviorNoNAT                Behavior = "no NAT"
+	BehaviorEndpointIndependent  Behavior = "endpoint independent"
+	BehaviorAddressDependent     Behavior = "address dependent"
+	BehaviorAddressPortDependent Behavior = "address and port dependent"
+)
+
+// Phase of a test reported to Observer.
+type Phase string
+
+// Possible test phases.
+const (
+	PhaseSent       Phase = "request sent"
+	PhaseResponse   Phase = "response received"
+	PhaseTimeout    Phase = "timed out"
+	PhaseConclusion Phase = "conclusion"
+)
+
+// Progress describes a phase of NAT discovery test.
+type Progress struct {
+	Test       string   // e.g. "Mapping Test II"
+	Phase      Phase    // current phase of Test
+	Addr       net.Addr // request destination for PhaseSent, PhaseResponse and PhaseTimeout
+	Timeout    time.Duration
+	Conclusion string // intermediate conclusion for PhaseConclusion
+}
+
+// Observer is called for each phase of NAT discovery tests, allowing GUIs
+// and CLIs to show progress while waiting for timeouts.
+type Observer func(p Progress)
+
+// Errors returned by NAT discovery tests.
+var (
+	ErrResponseMessage = errors.New("error reading from response message channel")
+	ErrTimedOut        = errors.New("timed out waiting for response")
+	ErrNoOtherAddress  = errors.New("no OTHER-ADDRESS in message")
+	ErrNoXORAddress    = errors.New("no XOR-MAPPED-ADDRESS in message")
+)
+
+const (
+	messageHeaderSize = 20
+	defaultTimeout    = 3 * time.Second
+)
+
+// Config configures NAT discovery tests.
+type Config struct {
+	// Server is address of STUN server supporting RFC 5780, e.g.
+	// "stun.voipgate.com:3478".
+	Server string
+	// Timeout is time to wait for each response, 3 seconds if zero.
+	Timeout time.Duration
+	// Observer is called for each test phase if not nil.
+	Observer Observer
+	// Logger is used for logging if not nil.
+	Logger logging.LeveledLogger
+}
+
+func (cfg Config) logger() logging.LeveledLogger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+
+	return logging.NewDefaultLoggerFactory().NewLogger("stunnat")
+}
+
+type stunServerConn struct {
+	conn        net.PacketConn
+	LocalAddr   net.Addr
+	RemoteAddr  *net.UDPAddr
+	OtherAddr   *net.UDPAddr
+	messageChan chan *stun.Message
+	done        chan struct{} // closed by Close to stop listen goroutine
+	closeOnce   sync.Once
+	log         logging.LeveledLogger
+	timeout     time.Duration
+	observe     Observer
+	test        string // current test name, reported to observe
+}
+
+func (c *stunServerConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	return c.conn.Close()
+}
+
+// Mapping determines NAT mapping behavior, RFC 5780 Section 4.3, returning
+// it along with XOR-MAPPED-ADDRESS of the first response.
+func (cfg Config) Mapping() (Behavior, *stun.XORMappedAddress, error) { //nolint:cyclop
+	log := cfg.logger()
+	mapTestConn, err := cfg.connect(log)
+	if err != nil {
+		log.Warnf("Error creating STUN connection: %s", err)
+
+		return "", nil, err
+	}
+	// Close on early return too, as tests may be run repeatedly.
+	defer func() { _ = mapTestConn.Close() }()
+
+	// Test I: Regular binding request
+	log.Info("Mapping Test I: Regular binding request")
+	mapTestConn.test = "Mapping Test I"
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+
+	resp, err := mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Parse response message for XOR-MAPPED-ADDRESS and make sure OTHER-ADDRESS valid
+	resps1 := mapTestConn.parse(resp)
+	if resps1.xorAddr == nil || resps1.otherAddr == nil {
+		log.Info("Error: NAT discovery feature not supported by this server")
+
+		return "", nil, ErrNoOtherAddress
+	}
+	addr, err := net.ResolveUDPAddr("udp4", resps1.otherAddr.String())
+	if err != nil {
+		log.Infof("Failed resolving OTHER-ADDRESS: %v", resps1.otherAddr)
+
+		return "", nil, err
+	}
+	mapTestConn.OtherAddr = addr
+	log.Infof("Received XOR-MAPPED-ADDRESS: %v", resps1.xorAddr)
+
+	// Assert mapping behavior
+	if resps1.xorAddr.String() == mapTestConn.LocalAddr.String() {
+		log.Warn("=> NAT mapping behavior: endpoint independent (no NAT)")
+		mapTestConn.conclude("mapping is " + string(BehaviorNoNAT))
+
+		return BehaviorNoNAT, resps1.xorAddr, mapTestConn.Close()
+	}
+
+	// Test II: Send binding request to the other address but primary port
+	log.Info("Mapping Test II: Send binding request to the other address but primary port")
+	mapTestConn.conclude("mapping is not " + string(BehaviorNoNAT))
+	mapTestConn.test = "Mapping Test II"
+	oaddr := *mapTestConn.OtherAddr
+	oaddr.Port = mapTestConn.RemoteAddr.Port
+	resp, err = mapTestConn.roundTrip(request, &oaddr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Assert mapping behavior
+	resps2 := mapTestConn.parse(resp)
+	if resps2.xorAddr == nil {
+		return "", nil, ErrNoXORAddress
+	}
+	log.Infof("Received XOR-MAPPED-ADDRESS: %v", resps2.xorAddr)
+	if resps2.xorAddr.String() == resps1.xorAddr.String() {
+		log.Warn("=> NAT mapping behavior: endpoint independent")
+		mapTestConn.conclude("mapping is " + string(BehaviorEndpointIndependent))
+
+		return BehaviorEndpointIndependent, resps1.xorAddr, mapTestConn.Close()
+	}
+
+	// Test III: Send binding request to the other address and port
+	log.Info("Mapping Test III: Send binding request to the other address and port")
+	mapTestConn.conclude("mapping is not " + string(BehaviorEndpointIndependent))
+	mapTestConn.test = "Mapping Test III"
+	resp, err = mapTestConn.roundTrip(request, mapTestConn.OtherAddr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Assert mapping behavior
+	resps3 := mapTestConn.parse(resp)
+	if resps3.xorAddr == nil {
+		return "", nil, ErrNoXORAddress
+	}
+	log.Infof("Received XOR-MAPPED-ADDRESS: %v", resps3.xorAddr)
+	behavior := BehaviorAddressPortDependent
+	if resps3.xorAddr.String() == resps2.xorAddr.String() {
+		behavior = BehaviorAddressDependent
+	}
+	log.Warnf("=> NAT mapping behavior: %s", behavior)
+	mapTestConn.conclude("mapping is " + string(behavior))
+
+	return behavior, resps1.xorAddr, mapTestConn.Close()
+}
+
+// Filtering determines NAT filtering behavior, RFC 5780 Section 4.4.
+func (cfg Config) Filtering() (Behavior, error) { //nolint:cyclop
+	log := cfg.logger()
+	mapTestConn, err := cfg.connect(log)
+	if err != nil {
+		log.Warnf("Error creating STUN connection: %s", err)
+
+		return "", err
+	}
+	// Close on early return too, as tests may be run repeatedly.
+	defer func() { _ = mapTestConn.Close() }()
+
+	// Test I: Regular binding request
+	log.Info("Filtering Test I: Regular binding request")
+	mapTestConn.test = "Filtering Test I"
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+
+	resp, err := mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
+	if err != nil {
+		return "", err
+	}
+	resps := mapTestConn.parse(resp)
+	if resps.xorAddr == nil || resps.otherAddr == nil {
+		log.Warn("Error: NAT discovery feature not supported by this server")
+
+		return "", ErrNoOtherAddress
+	}
+	addr, err := net.ResolveUDPAddr("udp4", resps.otherAddr.String())
+	if err != nil {
+		log.Infof("Failed resolving OTHER-ADDRESS: %v", resps.otherAddr)
+
+		return "", err
+	}
+	mapTestConn.OtherAddr = addr
+
+	// Test II: Request to change both IP and port
+	log.Info("Filtering Test II: Request to change both IP and port")
+	mapTestConn.test = "Filtering Test II"
+	request = stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	request.Add(stun.AttrChangeRequest, []byte{0x00, 0x00, 0x00, 0x06})
+
+	resp, err = mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
+	if err == nil {
+		mapTestConn.parse(resp) // just to print out the resp
+		log.Warn("=> NAT filtering behavior: endpoint independent")
+		mapTestConn.conclude("filtering is " + string(BehaviorEndpointIndependent))
+
+		return BehaviorEndpointIndependent, mapTestConn.Close()
+	} else if !errors.Is(err, ErrTimedOut) {
+		return "", err // something else went wrong
+	}
+
+	// Test III: Request to change port only
+	log.Info("Filtering Test III: Request to change port only")
+	mapTestConn.conclude("filtering is not " + string(BehaviorEndpointIndependent))
+	mapTestConn.test = "Filtering Test III"
+	request = stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	request.Add(stun.AttrChangeRequest, []byte{0x00, 0x00, 0x00, 0x02})
+
+	resp, err = mapTestConn.roundTrip(request, mapTestConn.RemoteAddr)
+	var behavior Behavior
+	switch {
+	case err == nil:
+		mapTestConn.parse(resp) // just to print out the resp
+		behavior = BehaviorAddressDependent
+	case errors.Is(err, ErrTimedOut):
+		behavior = BehaviorAddressPortDependent
+	default:
+		return "", err // something else went wrong
+	}
+	log.Warnf("=> NAT filtering behavior: %s", behavior)
+	mapTestConn.conclude("filtering is " + string(behavior))
+
+	return behavior, mapTestConn.Close()
+}
+
+// Parse a STUN message.
+func (c *stunServerConn) parse(msg *stun.Message) (ret struct {
+	xorAddr    *stun.XORMappedAddress
+	otherAddr  *stun.OtherAddress
+	respOrigin *stun.ResponseOrigin
+	mappedAddr *stun.MappedAddress
+	software   *stun.Software
+},
+) {
+	// Missing attributes are left nil.
+	ret.mappedAddr, _ = stun.Parse[stun.MappedAddress](msg)
+	ret.xorAddr, _ = stun.Parse[stun.XORMappedAddress](msg)
+	ret.respOrigin, _ = stun.Parse[stun.ResponseOrigin](msg)
+	ret.otherAddr, _ = stun.Parse[stun.OtherAddress](msg)
+	ret.software, _ = stun.Parse[stun.Software](msg)
+	c.log.Debugf("%v", msg)
+	c.log.Debugf("\tMAPPED-ADDRESS:     %v", ret.mappedAddr)
+	c.log.Debugf("\tXOR-MAPPED-ADDRESS: %v", ret.xorAddr)
+	c.log.Debugf("\tRESPONSE-ORIGIN:    %v", ret.respOrigin)
+	c.log.Debugf("\tOTHER-ADDRESS:      %v", ret.otherAddr)
+	c.log.Debugf("\tSOFTWARE: %v", ret.software)
+	for _, attr := range msg.Attributes {
+		switch attr.Type {
+		case
+			stun.AttrXORMappedAddress,
+			stun.AttrOtherAddress,
+			stun.AttrResponseOrigin,
+			stun.AttrMappedAddress,
+			stun.AttrSoftware:
+			break //nolint:staticcheck
+		default:
+			c.log.Debugf("\t%v (l=%v)", attr, attr.Length)
+		}
+	}
+
+	return ret
+}
+
+// connect returns stunServerConn to cfg.Server.
+func (cfg Config) connect(log logging.LeveledLogger) (*stunServerConn, error) {
+	log.Infof("Connecting to STUN server: %s", cfg.Server)
+	addr, err := net.ResolveUDPAddr("udp4", cfg.Server)
+	if err != nil {
+		log.Warnf("Error resolving address: %s", err)
+
+		return nil, err
+	}
+
+	c, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Local address: %s", c.LocalAddr())
+	log.Infof("Remote address: %s", addr.String())
+
+	done := make(chan struct{})
+	mChan := listen(c, log, done)
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	observe := cfg.Observer
+	if observe == nil {
+		observe = func(Progress) {}
+	}
+
+	return &stunServerConn{
+		conn:        c,
+		LocalAddr:   c.LocalAddr(),
+		RemoteAddr:  addr,
+		messageChan: mChan,
+		done:        done,
+		log:         log,
+		timeout:     timeout,
+		observe:     observe,
+	}, nil
+}
+
+// Send request and wait for response or timeout.
+func (c *stunServerConn) roundTrip(msg *stun.Message, addr net.Addr) (*stun.Message, error) {
+	_ = msg.NewTransactionID()
+	c.log.Infof("Sending to %v: (%v bytes)", addr, msg.Length+messageHeaderSize)
+	c.log.Debugf("%v", msg)
+	for _, attr := range msg.Attributes {
+		c.log.Debugf("\t%v (l=%v)", attr, attr.Length)
+	}
+	_, err := c.conn.WriteTo(msg.Raw, addr)
+	if err != nil {
+		c.log.Warnf("Error sending request to %v", addr)
+
+		return nil, err
+	}
+	c.observe(Progress{Test: c.test, Phase: PhaseSent, Addr: addr, Timeout: c.timeout})
+
+	// Wait for response or timeout
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case m, ok := <-c.messageChan:
+			if !ok {
+				return nil, ErrResponseMessage
+			}
+			if m.TransactionID != msg.TransactionID {
+				// E.g. late response to request of previous test.
+				c.log.Infof("Ignoring response to other transaction %x", m.TransactionID)
+
+				continue
+			}
+			c.observe(Progress{Test: c.test, Phase: PhaseResponse, Addr: addr})
+
+			return m, nil
+		case <-timer.C:
+			c.log.Infof("Timed out waiting for response from server %v", addr)
+			c.observe(Progress{Test: c.test, Phase: PhaseTimeout, Addr: addr})
+
+			return nil, ErrTimedOut
+		}
+	}
+}
+
+// conclude reports intermediate conclusion of the current test.
+func (c *stunServerConn) conclude(conclusion string) {
+	c.observe(Progress{Test: c.test, Phase: PhaseConclusion, Conclusion: conclusion})
+}
+
+// listen reads messages from conn until read fails or done is closed,
+// closing returned channel after that. Undecodable packets are skipped.
+func listen(conn *net.UDPConn, log logging.LeveledLogger, done <-chan struct{}) (messages chan *stun.Message) {
+	messages = make(chan *stun.Message)
+	go func() {
+		defer close(messages)
+		for {
+			buf := make([]byte, 1024)
+
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			log.Infof("Response from %v: (%v bytes)", addr, n)
+			buf = buf[:n]
+
+			m := new(stun.Message)
+			m.Raw = buf
+			err = m.Decode()
+			if err != nil {
+				log.Infof("Error decoding message: %v", err)
+
+				continue
+			}
+
+			select {
+			case messages <- m:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return
+}
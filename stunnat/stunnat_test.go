@@ -0,0 +1,232 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunnat
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/logging"
+	"github.com/pion/stun/v3"
+)
+
+// responder returns packets to send in response to n-th (starting from
+// zero) request req from addr to server listening on self.
+type responder func(n int, req *stun.Message, addr, self *net.UDPAddr) [][]byte
+
+// noNAT is responder with XOR-MAPPED-ADDRESS of sender and OTHER-ADDRESS
+// set to self if withOther, ignoring CHANGE-REQUEST, as if there was no
+// NAT between client and server.
+func noNAT(withOther bool) responder {
+	return func(_ int, req *stun.Message, addr, self *net.UDPAddr) [][]byte {
+		setters := []stun.Setter{
+			req, stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: addr.IP, Port: addr.Port},
+		}
+		if withOther {
+			setters = append(setters, &stun.OtherAddress{IP: self.IP, Port: self.Port})
+		}
+
+		return [][]byte{stun.MustBuild(setters...).Raw}
+	}
+}
+
+// serve responds to requests on conn via respond.
+func serve(t *testing.T, conn *net.UDPConn, respond responder) {
+	t.Helper()
+	self, _ := conn.LocalAddr().(*net.UDPAddr)
+	buf := make([]byte, 1500)
+	for n := 0; ; n++ {
+		size, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := new(stun.Message)
+		if err := stun.Decode(buf[:size], req); err != nil {
+			t.Error(err)
+
+			return
+		}
+		for _, b := range respond(n, req, addr, self) {
+			if _, err := conn.WriteToUDP(b, addr); err != nil {
+				t.Error(err)
+
+				return
+			}
+		}
+	}
+}
+
+func listenServer(t *testing.T, respond responder) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve(t, conn, respond)
+	}()
+	t.Cleanup(func() {
+		_ = conn.Close()
+		wg.Wait()
+	})
+
+	return conn.LocalAddr().String()
+}
+
+func TestConfig_Mapping(t *testing.T) {
+	var got []Progress
+	cfg := Config{
+		Server:  listenServer(t, noNAT(true)),
+		Timeout: time.Second,
+		Observer: func(p Progress) {
+			p.Addr, p.Timeout = nil, 0
+			got = append(got, p)
+		},
+	}
+	behavior, xorAddr, err := cfg.Mapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Client listens on unspecified address, so mapped address differs.
+	if behavior != BehaviorEndpointIndependent {
+		t.Errorf("unexpected behavior %q", behavior)
+	}
+	if !xorAddr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("unexpected XOR-MAPPED-ADDRESS %s", xorAddr)
+	}
+	expected := []Progress{
+		{Test: "Mapping Test I", Phase: PhaseSent},
+		{Test: "Mapping Test I", Phase: PhaseResponse},
+		{Test: "Mapping Test I", Phase: PhaseConclusion, Conclusion: "mapping is not no NAT"},
+		{Test: "Mapping Test II", Phase: PhaseSent},
+		{Test: "Mapping Test II", Phase: PhaseResponse},
+		{Test: "Mapping Test II", Phase: PhaseConclusion, Conclusion: "mapping is endpoint independent"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected progress:\n%+v\nexpected:\n%+v", got, expected)
+	}
+}
+
+func TestConfig_Filtering(t *testing.T) {
+	var phases []Phase
+	cfg := Config{
+		Server: listenServer(t, noNAT(true)),
+		Observer: func(p Progress) {
+			phases = append(phases, p.Phase)
+		},
+	}
+	behavior, err := cfg.Filtering()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if behavior != BehaviorEndpointIndependent {
+		t.Errorf("unexpected behavior %q", behavior)
+	}
+	expected := []Phase{PhaseSent, PhaseResponse, PhaseSent, PhaseResponse, PhaseConclusion}
+	if !reflect.DeepEqual(phases, expected) {
+		t.Errorf("unexpected phases %v, expected %v", phases, expected)
+	}
+}
+
+func TestConfig_NoOtherAddress(t *testing.T) {
+	cfg := Config{Server: listenServer(t, noNAT(false))}
+	if _, _, err := cfg.Mapping(); !errors.Is(err, ErrNoOtherAddress) {
+		t.Errorf("unexpected mapping error %v", err)
+	}
+	if _, err := cfg.Filtering(); !errors.Is(err, ErrNoOtherAddress) {
+		t.Errorf("unexpected filtering error %v", err)
+	}
+}
+
+func TestConfig_Timeout(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	var phases []Phase
+	cfg := Config{
+		Server:  conn.LocalAddr().String(),
+		Timeout: 50 * time.Millisecond,
+		Observer: func(p Progress) {
+			phases = append(phases, p.Phase)
+		},
+	}
+	if _, _, err := cfg.Mapping(); !errors.Is(err, ErrTimedOut) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if expected := []Phase{PhaseSent, PhaseTimeout}; !reflect.DeepEqual(phases, expected) {
+		t.Errorf("unexpected phases %v, expected %v", phases, expected)
+	}
+}
+
+func TestConfig_NoXORAddress(t *testing.T) {
+	respond := noNAT(true)
+	cfg := Config{Server: listenServer(t, func(n int, req *stun.Message, addr, self *net.UDPAddr) [][]byte {
+		if n == 0 {
+			return respond(n, req, addr, self)
+		}
+		// Only OTHER-ADDRESS in response to Test II.
+		return [][]byte{stun.MustBuild(req, stun.BindingSuccess, &stun.OtherAddress{IP: self.IP, Port: self.Port}).Raw}
+	})}
+	if _, _, err := cfg.Mapping(); !errors.Is(err, ErrNoXORAddress) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestConfig_UnexpectedPackets(t *testing.T) {
+	respond := noNAT(true)
+	cfg := Config{Server: listenServer(t, func(n int, req *stun.Message, addr, self *net.UDPAddr) [][]byte {
+		// Response to other transaction with other XOR-MAPPED-ADDRESS,
+		// e.g. late response to previous test, should be ignored as
+		// well as undecodable packet.
+		other := stun.MustBuild(stun.TransactionID, stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: net.IPv4(10, 0, 0, 1), Port: 1},
+			&stun.OtherAddress{IP: self.IP, Port: self.Port},
+		)
+
+		return append([][]byte{{1, 2, 3}, other.Raw}, respond(n, req, addr, self)...)
+	})}
+	behavior, _, err := cfg.Mapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if behavior != BehaviorEndpointIndependent {
+		t.Errorf("unexpected behavior %q", behavior)
+	}
+}
+
+func TestListen_Close(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	messages := listen(conn, logging.NewDefaultLoggerFactory().NewLogger("test"), done)
+	// Late response that is never received from messages.
+	if _, err = conn.WriteTo(stun.MustBuild(stun.TransactionID, stun.BindingSuccess).Raw, conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(done)
+	defer func() { _ = conn.Close() }()
+	// Goroutine blocked on sending should return on done.
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Error("message is sent after done")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listen goroutine is blocked")
+	}
+}
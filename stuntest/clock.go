@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is manually advanced clock, implementing stun.Clock, so timing of
+// transactions can be tested deterministically with stun.WithClock.
+type Clock struct {
+	mux     sync.Mutex
+	current time.Time
+}
+
+// NewClock returns Clock set to t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{current: t}
+}
+
+// Now returns current time of clock.
+func (c *Clock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.current
+}
+
+// Add advances clock by d, returning new current time.
+func (c *Clock) Add(d time.Duration) time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.current = c.current.Add(d)
+
+	return c.current
+}
+
+// Set sets current time of clock to t.
+func (c *Clock) Set(t time.Time) {
+	c.mux.Lock()
+	c.current = t
+	c.mux.Unlock()
+}
+
+// Collector is manually triggered collector, implementing stun.Collector,
+// so transactions are timed out only by Collect calls when passed to
+// stun.WithCollector.
+type Collector struct {
+	mux    sync.Mutex
+	f      func(now time.Time)
+	closed bool
+}
+
+// Start implements stun.Collector, storing f for Collect calls.
+func (c *Collector) Start(_ time.Duration, f func(now time.Time)) error {
+	c.mux.Lock()
+	c.f = f
+	c.mux.Unlock()
+
+	return nil
+}
+
+// Collect calls collect function with now, e.g. with Clock.Add result,
+// blocking until it returns. Does nothing if collector is not started or
+// closed.
+func (c *Collector) Collect(now time.Time) {
+	c.mux.Lock()
+	f, closed := c.f, c.closed
+	c.mux.Unlock()
+	if f == nil || closed {
+		return
+	}
+	f(now)
+}
+
+// Close implements stun.Collector, stopping further Collect calls.
+func (c *Collector) Close() error {
+	c.mux.Lock()
+	c.closed = true
+	c.mux.Unlock()
+
+	return nil
+}
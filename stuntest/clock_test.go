@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+	if !clock.Now().Equal(start) {
+		t.Error("unexpected initial time")
+	}
+	if now := clock.Add(time.Second); !now.Equal(start.Add(time.Second)) || !clock.Now().Equal(now) {
+		t.Errorf("unexpected time %s", now)
+	}
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Error("unexpected time after Set")
+	}
+}
+
+func TestCollector_Client(t *testing.T) {
+	const rto = 100 * time.Millisecond
+	var (
+		clock     = NewClock(time.Now())
+		collector = new(Collector)
+	)
+	collector.Collect(clock.Now()) // Not started, no-op.
+	connL, connR := net.Pipe()
+	defer connL.Close() //nolint:errcheck
+	client, err := stun.NewClient(connR,
+		stun.WithClock(clock),
+		stun.WithCollector(collector),
+		stun.WithRTO(rto),
+		stun.WithNoRetransmit,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, readErr := connL.Read(buf); readErr != nil {
+				return
+			}
+		}
+	}()
+	done := make(chan error, 1)
+	if err = client.Start(stun.MustBuild(stun.TransactionID, stun.BindingRequest), func(e stun.Event) {
+		done <- e.Error
+	}); err != nil {
+		t.Fatal(err)
+	}
+	collector.Collect(clock.Add(rto / 2))
+	select {
+	case err = <-done:
+		t.Fatalf("transaction should not time out before deadline, got %v", err)
+	default:
+	}
+	collector.Collect(clock.Add(rto))
+	select {
+	case err = <-done:
+		if !errors.Is(err, stun.ErrTransactionTimeOut) {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("transaction should time out")
+	}
+	if err = collector.Close(); err != nil {
+		t.Error(err)
+	}
+}
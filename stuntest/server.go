@@ -0,0 +1,298 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+const messageHeaderSize = 20
+
+var errServerUnsupportedNetwork = errors.New("unsupported network")
+
+// Behavior describes how Server handles single request.
+type Behavior struct {
+	Drop      bool          // do not respond
+	Delay     time.Duration // delay before response
+	ErrorCode stun.ErrorCode
+}
+
+// ServerOption configures Server.
+type ServerOption func(s *Server)
+
+// WithDelay delays all responses by d.
+func WithDelay(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.delay = d
+	}
+}
+
+// WithLoss drops fraction (from 0 to 1) of requests at random.
+func WithLoss(fraction float64) ServerOption {
+	return func(s *Server) {
+		s.loss = fraction
+	}
+}
+
+// WithErrorCode responds to all requests with error response of code.
+func WithErrorCode(code stun.ErrorCode) ServerOption {
+	return func(s *Server) {
+		s.errorCode = code
+	}
+}
+
+// WithScript sets function that returns behavior for n-th (starting from
+// zero) request, overriding other options, e.g. to drop the first request.
+func WithScript(f func(n int, req *stun.Message) Behavior) ServerOption {
+	return func(s *Server) {
+		s.script = f
+	}
+}
+
+// Server is fake STUN server that answers Binding requests with
+// XOR-MAPPED-ADDRESS of the request source, see NewServer.
+type Server struct {
+	addr      net.Addr
+	delay     time.Duration
+	loss      float64
+	errorCode stun.ErrorCode
+	script    func(n int, req *stun.Message) Behavior
+	requests  atomic.Int64
+	random    *rand.Rand
+	randMux   sync.Mutex
+	packet    net.PacketConn
+	listener  net.Listener
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+	mux       sync.Mutex
+	conns     map[net.Conn]struct{}
+	timers    map[*time.Timer]struct{} // of delayed responses
+	closed    bool
+}
+
+// NewServer starts Server on ephemeral loopback port of network, which is
+// one of "udp4", "udp6", "tcp4" or "tcp6". Server is closed on test
+// cleanup.
+func NewServer(t *testing.T, network string, opts ...ServerOption) (*Server, error) {
+	t.Helper()
+	s := &Server{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+		conns:  make(map[net.Conn]struct{}),
+		timers: make(map[*time.Timer]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	var ip string
+	switch network {
+	case "udp4", "tcp4":
+		ip = "127.0.0.1"
+	case "udp6", "tcp6":
+		ip = "::1"
+	default:
+		return nil, fmt.Errorf("%w: %s", errServerUnsupportedNetwork, network)
+	}
+	address := net.JoinHostPort(ip, "0")
+	switch network {
+	case "udp4", "udp6":
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return nil, err
+		}
+		s.packet, s.addr = conn, conn.LocalAddr()
+		s.wg.Add(1)
+		go s.servePacket()
+	default:
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		s.listener, s.addr = listener, listener.Addr()
+		s.wg.Add(1)
+		go s.serveStream()
+	}
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return s, nil
+}
+
+// Addr returns server address.
+func (s *Server) Addr() net.Addr {
+	return s.addr
+}
+
+// Requests returns number of received Binding requests, including dropped.
+func (s *Server) Requests() int {
+	return int(s.requests.Load())
+}
+
+// Close stops server and waits for its goroutines, delayed responses
+// that are not sent yet are dropped without waiting for their delay.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.mux.Lock()
+		s.closed = true
+		for timer := range s.timers {
+			timer.Stop()
+		}
+		s.timers = nil
+		s.mux.Unlock()
+		if s.packet != nil {
+			s.closeErr = s.packet.Close()
+		} else {
+			s.closeErr = s.listener.Close()
+			s.mux.Lock()
+			for conn := range s.conns {
+				_ = conn.Close()
+			}
+			s.mux.Unlock()
+		}
+		s.wg.Wait()
+	})
+
+	return s.closeErr
+}
+
+func (s *Server) servePacket() {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.packet.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.handle(buf[:n], addr, func(b []byte) {
+			_, _ = s.packet.WriteTo(b, addr)
+		})
+	}
+}
+
+func (s *Server) serveStream() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mux.Lock()
+		s.conns[conn] = struct{}{}
+		s.mux.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads STUN messages framed by their length from conn.
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mux.Lock()
+		delete(s.conns, conn)
+		s.mux.Unlock()
+		_ = conn.Close()
+	}()
+	var writeMux sync.Mutex
+	header := make([]byte, messageHeaderSize)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[2:4]))
+		buf := make([]byte, len(header)+length)
+		copy(buf, header)
+		if _, err := io.ReadFull(conn, buf[len(header):]); err != nil {
+			return
+		}
+		s.handle(buf, conn.RemoteAddr(), func(b []byte) {
+			writeMux.Lock()
+			_, _ = conn.Write(b)
+			writeMux.Unlock()
+		})
+	}
+}
+
+// handle responds to Binding request b from addr via write.
+func (s *Server) handle(b []byte, addr net.Addr, write func(b []byte)) {
+	req := new(stun.Message)
+	if err := stun.Decode(b, req); err != nil || req.Type != stun.BindingRequest {
+		return
+	}
+	behavior := s.behavior(int(s.requests.Add(1)-1), req)
+	if behavior.Drop {
+		return
+	}
+	var res *stun.Message
+	if behavior.ErrorCode != 0 {
+		res = stun.MustBuild(req, stun.BindingError, behavior.ErrorCode)
+	} else {
+		var mapped stun.XORMappedAddress
+		switch a := addr.(type) {
+		case *net.UDPAddr:
+			mapped.IP, mapped.Port = a.IP, a.Port
+		case *net.TCPAddr:
+			mapped.IP, mapped.Port = a.IP, a.Port
+		}
+		res = stun.MustBuild(req, stun.BindingSuccess, &mapped)
+	}
+	if behavior.Delay <= 0 {
+		write(res.Raw)
+
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(behavior.Delay, func() {
+		s.mux.Lock()
+		delete(s.timers, timer)
+		closed := s.closed
+		if !closed {
+			// Close waits only for responses that are being written.
+			s.wg.Add(1)
+		}
+		s.mux.Unlock()
+		if closed {
+			return
+		}
+		defer s.wg.Done()
+		write(res.Raw)
+	})
+	s.timers[timer] = struct{}{}
+}
+
+func (s *Server) behavior(n int, req *stun.Message) Behavior {
+	if s.script != nil {
+		return s.script(n, req)
+	}
+	behavior := Behavior{
+		Delay:     s.delay,
+		ErrorCode: s.errorCode,
+	}
+	if s.loss > 0 {
+		s.randMux.Lock()
+		behavior.Drop = s.random.Float64() < s.loss
+		s.randMux.Unlock()
+	}
+
+	return behavior
+}
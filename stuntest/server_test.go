@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// ping performs binding request to s, returning response or error.
+func ping(t *testing.T, s *Server, opts ...stun.ClientOption) (*stun.Message, error) {
+	t.Helper()
+	conn, err := net.Dial(s.Addr().Network(), s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := stun.NewClient(conn, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	var (
+		res    = new(stun.Message)
+		resErr error
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = client.DoContext(ctx, stun.MustBuild(stun.TransactionID, stun.BindingRequest), func(e stun.Event) {
+		if resErr = e.Error; resErr == nil {
+			resErr = e.Message.CloneTo(res)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if resErr != nil {
+		return nil, resErr
+	}
+	var mapped stun.XORMappedAddress
+	if res.Type == stun.BindingSuccess {
+		if err = mapped.GetFrom(res); err != nil {
+			t.Fatal(err)
+		}
+		if local := conn.LocalAddr().String(); mapped.String() != local {
+			t.Errorf("mapped address %s, expected %s", mapped, local)
+		}
+	}
+
+	return res, nil
+}
+
+func TestServer(t *testing.T) {
+	for _, network := range []string{"udp4", "tcp4"} {
+		t.Run(network, func(t *testing.T) {
+			s, err := NewServer(t, network)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := ping(t, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Type != stun.BindingSuccess || s.Requests() != 1 {
+				t.Errorf("unexpected response %s, requests %d", res, s.Requests())
+			}
+		})
+	}
+	t.Run("UnsupportedNetwork", func(t *testing.T) {
+		if _, err := NewServer(t, "ip4"); !errors.Is(err, errServerUnsupportedNetwork) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestServer_Behavior(t *testing.T) {
+	t.Run("ErrorCode", func(t *testing.T) {
+		s, err := NewServer(t, "udp4", WithErrorCode(stun.CodeServerError))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := ping(t, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var code stun.ErrorCodeAttribute
+		if err = code.GetFrom(res); err != nil || code.Code != stun.CodeServerError {
+			t.Errorf("unexpected error code %v, %v", code, err)
+		}
+	})
+	t.Run("Delay", func(t *testing.T) {
+		const delay = 50 * time.Millisecond
+		s, err := NewServer(t, "tcp4", WithDelay(delay))
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		if _, err = ping(t, s); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("response after %s, expected delay %s", elapsed, delay)
+		}
+	})
+	t.Run("CloseDelayed", func(t *testing.T) {
+		const delay = 10 * time.Second
+		s, err := NewServer(t, "udp4", WithDelay(delay))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial(s.Addr().Network(), s.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = conn.Close() }()
+		if _, err = conn.Write(stun.MustBuild(stun.TransactionID, stun.BindingRequest).Raw); err != nil {
+			t.Fatal(err)
+		}
+		for s.Requests() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		start := time.Now()
+		if err = s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > delay/10 {
+			t.Errorf("close waited for delayed response: %s", elapsed)
+		}
+	})
+	t.Run("Loss", func(t *testing.T) {
+		s, err := NewServer(t, "udp4", WithLoss(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = ping(t, s, stun.WithRTO(10*time.Millisecond)); !errors.Is(err, stun.ErrTransactionTimeOut) {
+			t.Errorf("unexpected error %v", err)
+		}
+		if s.Requests() < 2 {
+			t.Errorf("expected retransmissions, got %d requests", s.Requests())
+		}
+	})
+	t.Run("Script", func(t *testing.T) {
+		s, err := NewServer(t, "udp4", WithScript(func(n int, _ *stun.Message) Behavior {
+			return Behavior{Drop: n == 0}
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = ping(t, s, stun.WithRTO(10*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		if s.Requests() != 2 {
+			t.Errorf("expected response to retransmission, got %d requests", s.Requests())
+		}
+	})
+}
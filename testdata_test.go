@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"hash/crc64"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func loadData(tb testing.TB, name string) []byte {
+	tb.Helper()
+
+	name = filepath.Join("testdata", name)
+	f, err := os.Open(name) //nolint:gosec
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer func() {
+		if errClose := f.Close(); errClose != nil {
+			tb.Fatal(errClose)
+		}
+	}()
+	v, err := io.ReadAll(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return v
+}
+
+// loadBrowserMessages returns messages from frombrowsers.csv, checking
+// their crc64.
+func loadBrowserMessages(tb testing.TB) [][]byte {
+	tb.Helper()
+
+	// file contains udp-packets captured from browsers (WebRTC)
+	reader := csv.NewReader(bytes.NewReader(loadData(tb, "frombrowsers.csv")))
+	reader.Comment = '#'
+	_, err := reader.Read() // skipping header
+	if err != nil {
+		tb.Fatal("failed to skip header of csv: ", err)
+	}
+	crcTable := crc64.MakeTable(crc64.ISO)
+	var messages [][]byte
+	for {
+		line, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			tb.Fatal("failed to read csv line: ", err)
+		}
+		data, err := base64.StdEncoding.DecodeString(line[1])
+		if err != nil {
+			tb.Fatal("failed to decode ", line[1], " as base64: ", err)
+		}
+		b, err := strconv.ParseUint(line[2], 10, 64)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if b != crc64.Checksum(data, crcTable) {
+			tb.Error("crc64 check failed for ", line[1])
+		}
+		messages = append(messages, data)
+	}
+
+	return messages
+}
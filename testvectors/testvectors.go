@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package testvectors provides STUN test vectors from RFC 5769 and RFC 8489
+// as raw bytes with expected decoded fields, so packages that implement
+// STUN extensions can reuse them in tests and fuzzers.
+//
+// The package does not depend on stun, values are of basic types.
+package testvectors
+
+import "net"
+
+// Credentials are credentials used to compute MESSAGE-INTEGRITY or
+// MESSAGE-INTEGRITY-SHA256 of vector. Realm is empty for short-term
+// credentials. Values are SASL-prepared.
+type Credentials struct {
+	Username string
+	Realm    string
+	Password string
+}
+
+// Vector is STUN message test vector with expected decoded fields.
+// Zero field means that message has no corresponding attribute.
+type Vector struct {
+	Name          string
+	Raw           []byte
+	Type          uint16 // message type value
+	TransactionID [12]byte
+	Credentials   Credentials
+
+	Software          string
+	Username          string
+	Userhash          []byte
+	Realm             string
+	Nonce             string
+	Priority          uint32
+	ICEControlled     uint64
+	PasswordAlgorithm uint16
+	// XORMappedAddress is decoded XOR-MAPPED-ADDRESS.
+	XORMappedAddress *net.UDPAddr
+
+	MessageIntegrity       bool // has MESSAGE-INTEGRITY
+	MessageIntegritySHA256 bool // has MESSAGE-INTEGRITY-SHA256
+	Fingerprint            bool // has FINGERPRINT
+}
+
+//nolint:gochecknoglobals
+var (
+	shortTermCredentials = Credentials{
+		Username: "evtj:h6vY",
+		Password: "VOkJxbRl1RmTxUk/WvJxBt",
+	}
+	longTermCredentials = Credentials{
+		// "<U+30DE><U+30C8><U+30EA><U+30C3><U+30AF><U+30B9>"
+		Username: "マトリックス",
+		Realm:    "example.org",
+		// SASL-prepared "The<U+00AD>M<U+00AA>tr<U+2168>"
+		Password: "TheMatrIX",
+	}
+)
+
+// RFC5769 returns test vectors from RFC 5769 Section 2. Each call returns
+// new values, so they can be modified.
+func RFC5769() []Vector { //nolint:funlen
+	return []Vector{
+		{
+			Name: "Request", // Section 2.1
+			Raw: []byte("\x00\x01\x00\x58" +
+				"\x21\x12\xa4\x42" +
+				"\xb7\xe7\xa7\x01\xbc\x34\xd6\x86\xfa\x87\xdf\xae" +
+				"\x80\x22\x00\x10" +
+				"STUN test client" +
+				"\x00\x24\x00\x04" +
+				"\x6e\x00\x01\xff" +
+				"\x80\x29\x00\x08" +
+				"\x93\x2f\xf9\xb1\x51\x26\x3b\x36" +
+				"\x00\x06\x00\x09" +
+				"\x65\x76\x74\x6a\x3a\x68\x36\x76\x59\x20\x20\x20" +
+				"\x00\x08\x00\x14" +
+				"\x9a\xea\xa7\x0c\xbf\xd8\xcb\x56\x78\x1e\xf2\xb5" +
+				"\xb2\xd3\xf2\x49\xc1\xb5\x71\xa2" +
+				"\x80\x28\x00\x04" +
+				"\xe5\x7a\x3b\xcf",
+			),
+			Type: 0x0001,
+			TransactionID: [12]byte{
+				0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+			},
+			Credentials:      shortTermCredentials,
+			Software:         "STUN test client",
+			Username:         shortTermCredentials.Username,
+			Priority:         0x6e0001ff,
+			ICEControlled:    0x932ff9b151263b36,
+			MessageIntegrity: true,
+			Fingerprint:      true,
+		},
+		{
+			Name: "ResponseIPv4", // Section 2.2
+			Raw: []byte("\x01\x01\x00\x3c" +
+				"\x21\x12\xa4\x42" +
+				"\xb7\xe7\xa7\x01\xbc\x34\xd6\x86\xfa\x87\xdf\xae" +
+				"\x80\x22\x00\x0b" +
+				"\x74\x65\x73\x74\x20\x76\x65\x63\x74\x6f\x72\x20" +
+				"\x00\x20\x00\x08" +
+				"\x00\x01\xa1\x47\xe1\x12\xa6\x43" +
+				"\x00\x08\x00\x14" +
+				"\x2b\x91\xf5\x99\xfd\x9e\x90\xc3\x8c\x74\x89\xf9" +
+				"\x2a\xf9\xba\x53\xf0\x6b\xe7\xd7" +
+				"\x80\x28\x00\x04" +
+				"\xc0\x7d\x4c\x96",
+			),
+			Type: 0x0101,
+			TransactionID: [12]byte{
+				0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+			},
+			Credentials: shortTermCredentials,
+			Software:    "test vector",
+			XORMappedAddress: &net.UDPAddr{
+				IP:   net.ParseIP("192.0.2.1"),
+				Port: 32853,
+			},
+			MessageIntegrity: true,
+			Fingerprint:      true,
+		},
+		{
+			Name: "ResponseIPv6", // Section 2.3
+			Raw: []byte("\x01\x01\x00\x48" +
+				"\x21\x12\xa4\x42" +
+				"\xb7\xe7\xa7\x01\xbc\x34\xd6\x86\xfa\x87\xdf\xae" +
+				"\x80\x22\x00\x0b" +
+				"\x74\x65\x73\x74\x20\x76\x65\x63\x74\x6f\x72\x20" +
+				"\x00\x20\x00\x14" +
+				"\x00\x02\xa1\x47" +
+				"\x01\x13\xa9\xfa\xa5\xd3\xf1\x79" +
+				"\xbc\x25\xf4\xb5\xbe\xd2\xb9\xd9" +
+				"\x00\x08\x00\x14" +
+				"\xa3\x82\x95\x4e\x4b\xe6\x7b\xf1\x17\x84\xc9\x7c" +
+				"\x82\x92\xc2\x75\xbf\xe3\xed\x41" +
+				"\x80\x28\x00\x04" +
+				"\xc8\xfb\x0b\x4c",
+			),
+			Type: 0x0101,
+			TransactionID: [12]byte{
+				0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+			},
+			Credentials: shortTermCredentials,
+			Software:    "test vector",
+			XORMappedAddress: &net.UDPAddr{
+				IP:   net.ParseIP("2001:db8:1234:5678:11:2233:4455:6677"),
+				Port: 32853,
+			},
+			MessageIntegrity: true,
+			Fingerprint:      true,
+		},
+		{
+			Name: "RequestLongTerm", // Section 2.4
+			Raw: []byte("\x00\x01\x00\x60" +
+				"\x21\x12\xa4\x42" +
+				"\x78\xad\x34\x33\xc6\xad\x72\xc0\x29\xda\x41\x2e" +
+				"\x00\x06\x00\x12" +
+				"\xe3\x83\x9e\xe3\x83\x88\xe3\x83\xaa\xe3\x83\x83" +
+				"\xe3\x82\xaf\xe3\x82\xb9\x00\x00" +
+				"\x00\x15\x00\x1c" +
+				"\x66\x2f\x2f\x34\x39\x39\x6b\x39\x35\x34\x64\x36" +
+				"\x4f\x4c\x33\x34\x6f\x4c\x39\x46\x53\x54\x76\x79" +
+				"\x36\x34\x73\x41" +
+				"\x00\x14\x00\x0b" +
+				"\x65\x78\x61\x6d\x70\x6c\x65\x2e\x6f\x72\x67\x00" +
+				"\x00\x08\x00\x14" +
+				"\xf6\x70\x24\x65\x6d\xd6\x4a\x3e\x02\xb8\xe0\x71" +
+				"\x2e\x85\xc9\xa2\x8c\xa8\x96\x66",
+			),
+			Type: 0x0001,
+			TransactionID: [12]byte{
+				0x78, 0xad, 0x34, 0x33, 0xc6, 0xad, 0x72, 0xc0, 0x29, 0xda, 0x41, 0x2e,
+			},
+			Credentials:      longTermCredentials,
+			Username:         longTermCredentials.Username,
+			Realm:            longTermCredentials.Realm,
+			Nonce:            "f//499k954d6OL34oL9FSTvy64sA",
+			MessageIntegrity: true,
+		},
+	}
+}
+
+// RFC8489 returns test vectors from RFC 8489 Appendix B. Each call returns
+// new values, so they can be modified.
+//
+// The message length of the vector in Appendix B.1 is 0x9c in RFC, which is
+// inconsistent with its attributes; the correct value 0x90 is used, for which
+// the MESSAGE-INTEGRITY-SHA256 value in RFC is valid.
+func RFC8489() []Vector {
+	return []Vector{
+		{
+			Name: "RequestLongTermSHA256", // Appendix B.1
+			Raw: []byte("\x00\x01\x00\x90" +
+				"\x21\x12\xa4\x42" +
+				"\x78\xad\x34\x33\xc6\xad\x72\xc0\x29\xda\x41\x2e" +
+				"\x00\x1e\x00\x20" +
+				"\x4a\x3c\xf3\x8f\xef\x69\x92\xbd\xa9\x52\xc6\x78" +
+				"\x04\x17\xda\x0f\x24\x81\x94\x15\x56\x9e\x60\xb2" +
+				"\x05\xc4\x6e\x41\x40\x7f\x17\x04" +
+				"\x00\x15\x00\x29" +
+				"\x6f\x62\x4d\x61\x74\x4a\x6f\x73\x32\x41\x41\x41" +
+				"\x43\x66\x2f\x2f\x34\x39\x39\x6b\x39\x35\x34\x64" +
+				"\x36\x4f\x4c\x33\x34\x6f\x4c\x39\x46\x53\x54\x76" +
+				"\x79\x36\x34\x73\x41\x00\x00\x00" +
+				"\x00\x14\x00\x0b" +
+				"\x65\x78\x61\x6d\x70\x6c\x65\x2e\x6f\x72\x67\x00" +
+				"\x00\x1d\x00\x04" +
+				"\x00\x02\x00\x00" +
+				"\x00\x1c\x00\x20" +
+				"\xb5\xc7\xbf\x00\x5b\x6c\x52\xa2\x1c\x51\xc5\xe8" +
+				"\x92\xf8\x19\x24\x13\x62\x96\xcb\x92\x7c\x43\x14" +
+				"\x93\x09\x27\x8c\xc6\x51\x8e\x65",
+			),
+			Type: 0x0001,
+			TransactionID: [12]byte{
+				0x78, 0xad, 0x34, 0x33, 0xc6, 0xad, 0x72, 0xc0, 0x29, 0xda, 0x41, 0x2e,
+			},
+			Credentials: longTermCredentials,
+			Userhash: []byte{
+				0x4a, 0x3c, 0xf3, 0x8f, 0xef, 0x69, 0x92, 0xbd, 0xa9, 0x52, 0xc6, 0x78,
+				0x04, 0x17, 0xda, 0x0f, 0x24, 0x81, 0x94, 0x15, 0x56, 0x9e, 0x60, 0xb2,
+				0x05, 0xc4, 0x6e, 0x41, 0x40, 0x7f, 0x17, 0x04,
+			},
+			Realm:                  longTermCredentials.Realm,
+			Nonce:                  "obMatJos2AAACf//499k954d6OL34oL9FSTvy64sA",
+			PasswordAlgorithm:      0x0002, // SHA-256
+			MessageIntegritySHA256: true,
+		},
+	}
+}
+
+// All returns vectors of RFC5769 and RFC8489.
+func All() []Vector {
+	return append(RFC5769(), RFC8489()...)
+}
@@ -3,11 +3,28 @@
 
 package stun
 
-// NewUsername returns Username with provided value.
+import (
+	"errors"
+	"strings"
+)
+
+// NewUsername returns Username with provided value. Value is validated
+// by AddTo, see Username.Validate.
 func NewUsername(username string) Username {
 	return Username(username)
 }
 
+// ErrICEUsername means that USERNAME is not in "ufrag:ufrag" form.
+var ErrICEUsername = errors.New("USERNAME is not in ufrag:ufrag form")
+
+// NewICEUsername returns USERNAME for ICE connectivity check sent to agent
+// with receiver ufrag by agent with sender ufrag.
+//
+// RFC 8445 Section 7.2.2.
+func NewICEUsername(receiver, sender string) Username {
+	return Username(receiver + ":" + sender)
+}
+
 // Username represents USERNAME attribute.
 //
 // RFC 5389 Section 15.3.
@@ -19,9 +36,29 @@ func (u Username) String() string {
 
 const maxUsernameB = 513
 
-// AddTo adds USERNAME attribute to message.
+// AddTo adds USERNAME attribute to message if it is valid, see
+// Username.Validate.
 func (u Username) AddTo(m *Message) error {
-	return TextAttribute(u).AddToAs(m, AttrUsername, maxUsernameB)
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	m.Add(AttrUsername, u)
+
+	return nil
+}
+
+// ICE splits ICE USERNAME into ufrag of agent that receives request and
+// ufrag of agent that sends it, returning ErrICEUsername if any of them is
+// empty or there is no single colon.
+//
+// RFC 8445 Section 7.2.2.
+func (u Username) ICE() (receiver, sender string, err error) {
+	receiver, sender, found := strings.Cut(string(u), ":")
+	if !found || receiver == "" || sender == "" || strings.Contains(sender, ":") {
+		return "", "", ErrICEUsername
+	}
+
+	return receiver, sender, nil
 }
 
 // GetFrom gets USERNAME from message.
@@ -99,6 +99,13 @@ func TestUsername(t *testing.T) {
 			t.Errorf("AddTo should return *AttrOverflowErr, got: %v", err)
 		}
 	})
+	t.Run("Bad UTF-8", func(t *testing.T) {
+		var syntaxErr *TextSyntaxErr
+		err := NewUsername("user\xffname").AddTo(msg)
+		if !errors.As(err, &syntaxErr) || !errors.Is(err, ErrTextSyntax) || syntaxErr.Offset != 4 {
+			t.Errorf("AddTo should return *TextSyntaxErr, got: %v", err)
+		}
+	})
 	t.Run("AddTo", func(t *testing.T) {
 		if err := uName.AddTo(msg); err != nil {
 			t.Error("errored:", err)
@@ -135,6 +142,18 @@ func TestUsername(t *testing.T) {
 	})
 }
 
+func TestUsername_ICE(t *testing.T) {
+	receiver, sender, err := NewICEUsername("rfrag", "lfrag").ICE()
+	if err != nil || receiver != "rfrag" || sender != "lfrag" {
+		t.Errorf("unexpected %q, %q, %v", receiver, sender, err)
+	}
+	for _, v := range []string{"", "rfrag", ":lfrag", "rfrag:", "a:b:c"} {
+		if _, _, err := NewUsername(v).ICE(); !errors.Is(err, ErrICEUsername) {
+			t.Errorf("%q: unexpected error %v", v, err)
+		}
+	}
+}
+
 func TestRealm_GetFrom(t *testing.T) {
 	msg := New()
 	val := "realm"
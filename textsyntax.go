@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxQuotedTextChars is maximum number of characters in REALM and NONCE,
+// both MUST be fewer than 128 characters.
+const maxQuotedTextChars = 127
+
+var (
+	// ErrTextTooLong means that text attribute has more than 127 characters.
+	ErrTextTooLong = errors.New("text attribute has 128 or more characters")
+	// ErrTextSyntax means that text attribute contains character that is
+	// not allowed by its grammar.
+	ErrTextSyntax = errors.New("invalid character in text attribute")
+)
+
+// TextSyntaxErr describes invalid USERNAME, REALM or NONCE value.
+//
+//nolint:errname
+type TextSyntaxErr struct {
+	Attr   AttrType // attribute type
+	Offset int      // byte offset of invalid character, or -1
+	Err    error    // ErrTextTooLong or ErrTextSyntax
+}
+
+func (e *TextSyntaxErr) Error() string {
+	if e.Offset < 0 {
+		return e.Attr.String() + ": " + e.Err.Error()
+	}
+
+	return e.Attr.String() + ": " + e.Err.Error() + " at offset " + strconv.Itoa(e.Offset)
+}
+
+// Unwrap returns ErrTextTooLong or ErrTextSyntax.
+func (e *TextSyntaxErr) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that USERNAME value is valid UTF-8 sequence of at most
+// 513 bytes as required by RFC 8489 Section 14.3, returning error of
+// CheckOverflow or *TextSyntaxErr.
+func (u Username) Validate() error {
+	if err := CheckOverflow(AttrUsername, len(u), maxUsernameB); err != nil {
+		return err
+	}
+	if utf8.Valid(u) {
+		return nil
+	}
+	for i := 0; i < len(u); {
+		r, size := utf8.DecodeRune(u[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return &TextSyntaxErr{Attr: AttrUsername, Offset: i, Err: ErrTextSyntax}
+		}
+		i += size
+	}
+
+	return nil
+}
+
+// Validate checks REALM value syntax as defined in RFC 8489 Section 14.9:
+// sequence of qdtext or quoted-pair from RFC 3261 (i.e. quoted-string
+// without surrounding quotes) with fewer than 128 characters.
+//
+// Encoding does not validate values, so Validate can be used to catch
+// values that are rejected by the other side.
+func (n Realm) Validate() error {
+	return validateQuotedText(AttrRealm, n)
+}
+
+// Validate checks NONCE value syntax as defined in RFC 8489 Section 14.10:
+// sequence of qdtext or quoted-pair from RFC 3261 (so it has no unescaped
+// quotes) with fewer than 128 characters.
+func (n Nonce) Validate() error {
+	return validateQuotedText(AttrNonce, n)
+}
+
+// validateQuotedText checks that v consists of at most maxQuotedTextChars
+// qdtext or quoted-pair elements, where
+//
+//	qdtext      = LWS / %x21 / %x23-5B / %x5D-7E / UTF8-NONASCII
+//	quoted-pair = "\" (%x00-09 / %x0B-0C / %x0E-7F)
+//	LWS         = [*WSP CRLF] 1*WSP
+func validateQuotedText(t AttrType, v []byte) error { //nolint:cyclop
+	invalid := func(offset int) error {
+		return &TextSyntaxErr{Attr: t, Offset: offset, Err: ErrTextSyntax}
+	}
+	chars := 0
+	for i := 0; i < len(v); chars++ {
+		c := v[i]
+		switch {
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(v[i:])
+			if r == utf8.RuneError && size <= 1 {
+				return invalid(i)
+			}
+			i += size
+		case c == '\\':
+			if i+1 >= len(v) || v[i+1] >= utf8.RuneSelf || v[i+1] == '\n' || v[i+1] == '\r' {
+				return invalid(i)
+			}
+			i += 2
+			chars++ // quoted-pair is two characters
+		case c == '\r':
+			// Line folding, CRLF must be followed by whitespace.
+			if i+2 >= len(v) || v[i+1] != '\n' || (v[i+2] != ' ' && v[i+2] != '\t') {
+				return invalid(i)
+			}
+			i += 2
+			chars++ // CRLF is two characters
+		case c == ' ', c == '\t', c == 0x21,
+			c >= 0x23 && c <= 0x5b,
+			c >= 0x5d && c <= 0x7e:
+			i++
+		default:
+			return invalid(i)
+		}
+	}
+	if chars > maxQuotedTextChars {
+		return &TextSyntaxErr{Attr: t, Offset: -1, Err: ErrTextTooLong}
+	}
+
+	return nil
+}
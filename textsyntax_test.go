@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuotedTextValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		value  string
+		err    error
+		offset int
+	}{
+		{name: "Empty", value: ""},
+		{name: "Simple", value: "example.org"},
+		{name: "RFC5769", value: "f//499k954d6OL34oL9FSTvy64sA"},
+		{name: "NonASCII", value: "マトリックス"},
+		{name: "QuotedPair", value: `say \"hi\"`},
+		{name: "Folding", value: "a\r\n b"},
+		{name: "Max", value: strings.Repeat("a", 127)},
+		{name: "MaxNonASCII", value: strings.Repeat("マ", 127)},
+		{name: "Quote", value: `a"b`, err: ErrTextSyntax, offset: 1},
+		{name: "Backslash", value: `ab\`, err: ErrTextSyntax, offset: 2},
+		{name: "QuotedNewline", value: "a\\\nb", err: ErrTextSyntax, offset: 1},
+		{name: "Control", value: "a\x00", err: ErrTextSyntax, offset: 1},
+		{name: "CRLF", value: "a\r\nb", err: ErrTextSyntax, offset: 1},
+		{name: "BadUTF8", value: "ab\xff", err: ErrTextSyntax, offset: 2},
+		{name: "TooLong", value: strings.Repeat("a", 128), err: ErrTextTooLong, offset: -1},
+		{name: "TooLongQuoted", value: strings.Repeat("a", 126) + `\"`, err: ErrTextTooLong, offset: -1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, v := range []interface{ Validate() error }{
+				NewRealm(tc.value), NewNonce(tc.value),
+			} {
+				err := v.Validate()
+				if tc.err == nil {
+					if err != nil {
+						t.Errorf("%T: unexpected error %v", v, err)
+					}
+
+					continue
+				}
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("%T: unexpected error %v", v, err)
+				}
+				var syntaxErr *TextSyntaxErr
+				if !errors.As(err, &syntaxErr) {
+					t.Fatalf("%T: %v is not TextSyntaxErr", v, err)
+				}
+				if syntaxErr.Offset != tc.offset {
+					t.Errorf("%T: offset %d, expected %d", v, syntaxErr.Offset, tc.offset)
+				}
+			}
+		})
+	}
+	err := NewNonce(`"`).Validate()
+	if expected := "NONCE: invalid character in text attribute at offset 0"; err.Error() != expected {
+		t.Errorf("%q != %q", err, expected)
+	}
+}
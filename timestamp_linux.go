@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+// +build linux
+
+package stun
+
+import (
+	"encoding/binary"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/cpu"
+)
+
+// timestampOOBSize is size of control message buffer for SCM_TIMESTAMPNS.
+var timestampOOBSize = syscall.CmsgSpace(16) //nolint:gochecknoglobals
+
+// enableTimestamps enables SO_TIMESTAMPNS on conn socket.
+func enableTimestamps(conn Connection) error {
+	sc, ok := conn.(syscall.Conn)
+	if _, isMsg := conn.(msgReader); !ok || !isMsg {
+		return ErrKernelTimestampsNotSupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	return control(raw, func(fd int) error {
+		return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, 1)
+	})
+}
+
+// parseTimestamp returns SCM_TIMESTAMPNS time from control messages,
+// zero if there is none.
+func parseTimestamp(oob []byte) time.Time {
+	if len(oob) == 0 {
+		return time.Time{}
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}
+	}
+	for _, msg := range msgs {
+		if msg.Header.Level != syscall.SOL_SOCKET || msg.Header.Type != syscall.SCM_TIMESTAMPNS {
+			continue
+		}
+		// Timespec of two native words, 4 or 8 bytes long.
+		size := len(msg.Data) / 2
+		if size != 4 && size != 8 {
+			return time.Time{}
+		}
+
+		return time.Unix(nativeInt(msg.Data[:size]), nativeInt(msg.Data[size:2*size]))
+	}
+
+	return time.Time{}
+}
+
+// nativeInt decodes signed native-endian integer of 4 or 8 bytes.
+func nativeInt(b []byte) int64 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if cpu.IsBigEndian {
+		order = binary.BigEndian
+	}
+	if len(b) == 4 {
+		return int64(int32(order.Uint32(b))) //nolint:gosec // G115
+	}
+
+	return int64(order.Uint64(b)) //nolint:gosec // G115
+}
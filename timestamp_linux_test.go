@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+// +build linux
+
+package stun
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClient_KernelTimestamps(t *testing.T) {
+	server, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := server.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	conn, err := net.DialUDP("udp4", nil, server.LocalAddr().(*net.UDPAddr)) //nolint:forcetypeassert
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(conn, WithKernelTimestamps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	go func() {
+		buf := make([]byte, 1500)
+		req := new(Message)
+		for {
+			n, addr, readErr := server.ReadFrom(buf)
+			if readErr != nil {
+				return
+			}
+			if Decode(buf[:n], req) != nil {
+				continue
+			}
+			if _, writeErr := server.WriteTo(MustBuild(req, BindingSuccess).Raw, addr); writeErr != nil {
+				t.Error(writeErr)
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if err = client.DoContext(ctx, MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+
+			return
+		}
+		handled := time.Now()
+		if e.ReceivedAt.IsZero() || e.ReceivedAt.Before(start.Add(-time.Second)) || e.ReceivedAt.After(handled) {
+			t.Errorf("unexpected receive timestamp %s, handled at %s", e.ReceivedAt, handled)
+		}
+		if e.RTT <= 0 || e.RTT > time.Second {
+			t.Errorf("unexpected RTT %s", e.RTT)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	if ts := parseTimestamp(nil); !ts.IsZero() {
+		t.Error("should be zero")
+	}
+	if ts := parseTimestamp([]byte{1, 2, 3}); !ts.IsZero() {
+		t.Error("should be zero for invalid control message")
+	}
+}
@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+// +build !linux
+
+package stun
+
+import "time"
+
+const timestampOOBSize = 0
+
+func enableTimestamps(Connection) error {
+	return ErrKernelTimestampsNotSupported
+}
+
+func parseTimestamp([]byte) time.Time {
+	return time.Time{}
+}
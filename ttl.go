@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package stun
+
+import (
+	"net"
+	"syscall"
+)
+
+// setTTL sets IP TTL or IPv6 hop limit of conn socket to ttl, returning
+// function that restores the previous value.
+func setTTL(conn Connection, ttl int) (restore func() error, err error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, ErrTTLNotSupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	level, opt := syscall.IPPROTO_IP, syscall.IP_TTL
+	if addr, isUDP := localAddr(conn).(*net.UDPAddr); !isUDP {
+		return nil, ErrTTLNotSupported
+	} else if addr.IP.To4() == nil {
+		level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS
+	}
+	var prev int
+	if err = control(raw, func(fd int) (sockErr error) {
+		if prev, sockErr = syscall.GetsockoptInt(fd, level, opt); sockErr != nil {
+			return sockErr
+		}
+
+		return syscall.SetsockoptInt(fd, level, opt, ttl)
+	}); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return control(raw, func(fd int) error {
+			return syscall.SetsockoptInt(fd, level, opt, prev)
+		})
+	}, nil
+}
+
+// control calls f with socket descriptor of raw, returning error of f.
+func control(raw syscall.RawConn, f func(fd int) error) error {
+	var fErr error
+	if err := raw.Control(func(fd uintptr) {
+		fErr = f(int(fd))
+	}); err != nil {
+		return err
+	}
+
+	return fErr
+}
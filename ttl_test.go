@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func getTTL(t *testing.T, conn *net.UDPConn) int {
+	t.Helper()
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ttl int
+	if err = control(raw, func(fd int) (sockErr error) {
+		ttl, sockErr = syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL)
+
+		return sockErr
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return ttl
+}
+
+func TestClient_TTLOpt(t *testing.T) {
+	server, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := server.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	conn, err := net.DialUDP("udp4", nil, server.LocalAddr().(*net.UDPAddr)) //nolint:forcetypeassert
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultTTL := getTTL(t, conn)
+	client, err := NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closeErr := client.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	}()
+	go func() {
+		buf := make([]byte, 1500)
+		req := new(Message)
+		for {
+			n, addr, readErr := server.ReadFrom(buf)
+			if readErr != nil {
+				return
+			}
+			if Decode(buf[:n], req) != nil {
+				continue
+			}
+			if _, writeErr := server.WriteTo(MustBuild(req, BindingSuccess).Raw, addr); writeErr != nil {
+				t.Error(writeErr)
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = client.DoContext(ctx, MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+		}
+	}, TTLOpt(defaultTTL-1)); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := getTTL(t, conn); ttl != defaultTTL {
+		t.Errorf("TTL is not restored: %d != %d", ttl, defaultTTL)
+	}
+	t.Run("NotSupported", func(t *testing.T) {
+		noopClient, clientErr := NewClient(noopConnection{})
+		if clientErr != nil {
+			t.Fatal(clientErr)
+		}
+		if startErr := noopClient.Start(MustBuild(TransactionID, BindingRequest), func(Event) {},
+			TTLOpt(1),
+		); !errors.Is(startErr, ErrTTLNotSupported) {
+			t.Errorf("unexpected error %v", startErr)
+		}
+		if closeErr := noopClient.Close(); closeErr != nil {
+			t.Error(closeErr)
+		}
+	})
+}
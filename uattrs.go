@@ -67,3 +67,32 @@ func (a *UnknownAttributes) GetFrom(m *Message) error {
 
 	return nil
 }
+
+// UnknownComprehensionRequired returns comprehension-required attributes of
+// m that are not in known list, each type once in order of appearance.
+// Returns nil if there are no such attributes.
+//
+// Request with such attributes must be rejected with error response 420
+// (Unknown Attribute) that includes UNKNOWN-ATTRIBUTES with returned types,
+// see RFC 5389 Section 7.3.1.
+func (m *Message) UnknownComprehensionRequired(known []AttrType) []AttrType {
+	var unknown []AttrType
+	for _, a := range m.Attributes {
+		if !a.Type.Required() || containsAttrType(known, a.Type) || containsAttrType(unknown, a.Type) {
+			continue
+		}
+		unknown = append(unknown, a.Type)
+	}
+
+	return unknown
+}
+
+func containsAttrType(types []AttrType, t AttrType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
@@ -74,3 +74,28 @@ func BenchmarkUnknownAttributes(b *testing.B) {
 		}
 	})
 }
+
+func TestMessage_UnknownComprehensionRequired(t *testing.T) {
+	msg := MustBuild(BindingRequest,
+		NewUsername("user"),
+		RawAttribute{Type: AttrType(0x7001), Value: []byte{1}},
+		RawAttribute{Type: AttrType(0x8001), Value: []byte{2}}, // comprehension-optional
+		RawAttribute{Type: AttrType(0x7001), Value: []byte{3}},
+		RawAttribute{Type: AttrPriority, Value: []byte{0, 0, 0, 1}},
+	)
+	unknown := msg.UnknownComprehensionRequired([]AttrType{AttrUsername})
+	expected := []AttrType{AttrType(0x7001), AttrPriority}
+	if len(unknown) != len(expected) {
+		t.Fatalf("unexpected %v", unknown)
+	}
+	for i, v := range expected {
+		if unknown[i] != v {
+			t.Errorf("unknown[%d] = %s, expected %s", i, unknown[i], v)
+		}
+	}
+	if unknown := msg.UnknownComprehensionRequired([]AttrType{
+		AttrUsername, AttrType(0x7001), AttrPriority,
+	}); unknown != nil {
+		t.Errorf("unexpected %v", unknown)
+	}
+}
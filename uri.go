@@ -5,6 +5,7 @@ package stun
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"net/url"
 	"strconv"
@@ -31,6 +32,9 @@ var (
 
 	// ErrProtoType indicates an unsupported transport type was provided.
 	ErrProtoType = errors.New("invalid transport protocol type")
+
+	// ErrInvalidURI indicates that URI syntax is malformed.
+	ErrInvalidURI = errors.New("invalid URI")
 )
 
 // SchemeType indicates the type of server used in the ice.URL structure.
@@ -134,13 +138,47 @@ type URI struct {
 	Proto    ProtoType
 }
 
+// ParseOption configures ParseURI.
+type ParseOption func(o *parseOptions)
+
+type parseOptions struct {
+	port    int
+	tlsPort int
+}
+
+// WithDefaultPort sets port used for "stun" and "turn" URIs without port,
+// instead of DefaultPort.
+func WithDefaultPort(port int) ParseOption {
+	return func(o *parseOptions) {
+		o.port = port
+	}
+}
+
+// WithDefaultTLSPort sets port used for "stuns" and "turns" URIs without
+// port, instead of DefaultTLSPort, e.g. 443.
+func WithDefaultTLSPort(port int) ParseOption {
+	return func(o *parseOptions) {
+		o.tlsPort = port
+	}
+}
+
 // ParseURI parses a STUN or TURN urls following the ABNF syntax described in
 // https://tools.ietf.org/html/rfc7064 and https://tools.ietf.org/html/rfc7065
 // respectively.
-func ParseURI(raw string) (*URI, error) { //nolint:gocognit,cyclop
+//
+// Returned error matches one of ErrInvalidURI, ErrSchemeType, ErrHost,
+// ErrPort, ErrSTUNQuery, ErrInvalidQuery or ErrProtoType.
+func ParseURI(raw string, opts ...ParseOption) (*URI, error) { //nolint:cyclop
+	options := parseOptions{
+		port:    DefaultPort,
+		tlsPort: DefaultTLSPort,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
 	rawParts, err := url.Parse(raw)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
 	}
 
 	var uri URI
@@ -149,40 +187,12 @@ func ParseURI(raw string) (*URI, error) { //nolint:gocognit,cyclop
 		return nil, ErrSchemeType
 	}
 
-	var rawPort string
-	if uri.Host, rawPort, err = net.SplitHostPort(rawParts.Opaque); err != nil { //nolint:nestif
-		var e *net.AddrError
-		if errors.As(err, &e) {
-			if e.Err == "missing port in address" {
-				nextRawURL := uri.Scheme.String() + ":" + rawParts.Opaque
-				switch {
-				case uri.Scheme == SchemeTypeSTUN || uri.Scheme == SchemeTypeTURN:
-					nextRawURL += ":3478"
-					if rawParts.RawQuery != "" {
-						nextRawURL += "?" + rawParts.RawQuery
-					}
-
-					return ParseURI(nextRawURL)
-				case uri.Scheme == SchemeTypeSTUNS || uri.Scheme == SchemeTypeTURNS:
-					nextRawURL += ":5349"
-					if rawParts.RawQuery != "" {
-						nextRawURL += "?" + rawParts.RawQuery
-					}
-
-					return ParseURI(nextRawURL)
-				}
-			}
-		}
-
-		return nil, err
-	}
-
-	if uri.Host == "" {
-		return nil, ErrHost
+	defaultPort := options.port
+	if uri.IsSecure() {
+		defaultPort = options.tlsPort
 	}
-
-	if uri.Port, err = strconv.Atoi(rawPort); err != nil {
-		return nil, ErrPort
+	if uri.Host, uri.Port, err = splitHostPort(rawParts.Opaque, defaultPort); err != nil {
+		return nil, err
 	}
 
 	switch uri.Scheme {
@@ -225,9 +235,31 @@ func ParseURI(raw string) (*URI, error) { //nolint:gocognit,cyclop
 	return &uri, nil
 }
 
+// splitHostPort splits opaque part of URI into host and port, using
+// defaultPort if port is missing.
+func splitHostPort(address string, defaultPort int) (string, int, error) {
+	host, rawPort, err := net.SplitHostPort(address)
+	var addrErr *net.AddrError
+	if errors.As(err, &addrErr) && addrErr.Err == "missing port in address" {
+		host, rawPort, err = net.SplitHostPort(address + ":" + strconv.Itoa(defaultPort))
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrHost, err)
+	}
+	if host == "" {
+		return "", 0, ErrHost
+	}
+	port, err := strconv.ParseUint(rawPort, 10, 16)
+	if err != nil || port == 0 {
+		return "", 0, ErrPort
+	}
+
+	return host, int(port), nil
+}
+
 func parseProto(raw string) (ProtoType, error) {
 	qArgs, err := url.ParseQuery(raw)
-	if err != nil || len(qArgs) > 1 {
+	if err != nil || len(qArgs) > 1 || len(qArgs["transport"]) > 1 {
 		return ProtoTypeUnknown, ErrInvalidQuery
 	}
 
@@ -256,6 +288,29 @@ func (u URI) String() string {
 	return rawURL
 }
 
+// MarshalText implements encoding.TextMarshaler, encoding URI as String.
+// Username and Password are not part of URI, so they are not encoded.
+//
+// Together with UnmarshalText, allows using URI in JSON, YAML or other
+// text-based configuration.
+func (u URI) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing text with
+// ParseURI. Username and Password are left unchanged.
+func (u *URI) UnmarshalText(text []byte) error {
+	parsed, err := ParseURI(string(text))
+	if err != nil {
+		return err
+	}
+	parsed.Username = u.Username
+	parsed.Password = u.Password
+	*u = *parsed
+
+	return nil
+}
+
 // IsSecure returns whether the this URL's scheme describes secure scheme or not.
 func (u URI) IsSecure() bool {
 	return u.Scheme == SchemeTypeSTUNS || u.Scheme == SchemeTypeTURNS
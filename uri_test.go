@@ -4,6 +4,7 @@
 package stun
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -63,6 +64,37 @@ func TestParseURL(t *testing.T) {
 		}
 	})
 
+	t.Run("Typed errors", func(t *testing.T) {
+		for raw, expected := range map[string]error{
+			":::":                           ErrInvalidURI,
+			"stun:[::1]:123:":               ErrHost,
+			"stun:[::1]x":                   ErrHost,
+			"stun:[fe80::1%25eth0]\xc63478": ErrHost,
+			"stun:google.de:0":              ErrPort,
+			"stun:google.de:-1":             ErrPort,
+			"stun:google.de:65536":          ErrPort,
+			"turn:google.de?transport=udp&transport=tcp": ErrInvalidQuery,
+		} {
+			_, err := ParseURI(raw)
+			assert.ErrorIs(t, err, expected, raw)
+		}
+	})
+
+	t.Run("DefaultPorts", func(t *testing.T) {
+		opts := []ParseOption{WithDefaultPort(80), WithDefaultTLSPort(443)}
+		for raw, expected := range map[string]string{
+			"stun:google.de":                "stun:google.de:80",
+			"stuns:google.de":               "stuns:google.de:443",
+			"turns:google.de?transport=tcp": "turns:google.de:443?transport=tcp",
+			"stuns:google.de:5349":          "stuns:google.de:5349",
+		} {
+			uri, err := ParseURI(raw, opts...)
+			if assert.NoError(t, err, raw) {
+				assert.Equal(t, expected, uri.String(), raw)
+			}
+		}
+	})
+
 	t.Run("Failure", func(t *testing.T) {
 		testCases := []struct {
 			rawURL      string
@@ -99,3 +131,32 @@ func TestParseURL(t *testing.T) {
 		}
 	})
 }
+
+func TestURI_Text(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var config struct {
+			Servers []URI `json:"servers"`
+		}
+		raw := `{"servers":["stun:example.org","turns:[::1]:443?transport=tcp"]}`
+		assert.NoError(t, json.Unmarshal([]byte(raw), &config))
+		assert.Equal(t, []URI{
+			{Scheme: SchemeTypeSTUN, Host: "example.org", Port: 3478, Proto: ProtoTypeUDP},
+			{Scheme: SchemeTypeTURNS, Host: "::1", Port: 443, Proto: ProtoTypeTCP},
+		}, config.Servers)
+		encoded, err := json.Marshal(config)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"servers":["stun:example.org:3478","turns:[::1]:443?transport=tcp"]}`, string(encoded))
+	})
+	t.Run("KeepCredentials", func(t *testing.T) {
+		u := URI{Username: "user", Password: "pass"}
+		assert.NoError(t, u.UnmarshalText([]byte("turn:example.org")))
+		assert.Equal(t, "user", u.Username)
+		assert.Equal(t, "pass", u.Password)
+		assert.Equal(t, "turn:example.org:3478?transport=udp", u.String())
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		var u URI
+		assert.ErrorIs(t, u.UnmarshalText([]byte("google.de")), ErrSchemeType)
+		assert.Equal(t, URI{}, u)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"strconv"
 
 	"github.com/pion/transport/v3/utils/xor"
@@ -151,3 +152,20 @@ func (a *XORMappedAddress) GetFromAs(msg *Message, attr AttrType) error {
 func (a *XORMappedAddress) GetFrom(m *Message) error {
 	return a.GetFromAs(m, AttrXORMappedAddress)
 }
+
+// GetXORAddrPort decodes XOR-MAPPED-ADDRESS value of attribute t in
+// message m, e.g. AttrXORMappedAddress or AttrXORPeerAddress.
+//
+// Unlike GetFrom, it does not allocate and returned value does not alias
+// message memory, so it is suitable for per-packet hot paths.
+func GetXORAddrPort(m *Message, t AttrType) (netip.AddrPort, error) {
+	value, err := m.Get(t)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	var key [net.IPv6len]byte
+	bin.PutUint32(key[0:4], magicCookie)
+	copy(key[4:], m.TransactionID[:])
+
+	return decodeAddrPort(t, value, &key)
+}
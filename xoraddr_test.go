@@ -14,7 +14,10 @@ import (
 	"errors"
 	"io"
 	"net"
+	"net/netip"
 	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 func BenchmarkXORMappedAddress_AddTo(b *testing.B) {
@@ -228,3 +231,39 @@ func TestXORMappedAddress_String(t *testing.T) {
 		}
 	}
 }
+
+func TestGetXORAddrPort(t *testing.T) {
+	for _, str := range []string{"213.141.156.236:21254", "[fe80::dc2b:44ff:fe20:6009]:21254"} {
+		expected := netip.MustParseAddrPort(str)
+		msg := MustBuild(TransactionID, &XORMappedAddress{
+			IP:   expected.Addr().AsSlice(),
+			Port: int(expected.Port()),
+		})
+		var (
+			got netip.AddrPort
+			err error
+		)
+		testutil.ShouldNotAllocate(t, func() {
+			got, err = GetXORAddrPort(msg, AttrXORMappedAddress)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Errorf("got %s, expected %s", got, expected)
+		}
+	}
+	if _, err := GetXORAddrPort(New(), AttrXORMappedAddress); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func BenchmarkGetXORAddrPort(b *testing.B) {
+	m := MustBuild(TransactionID, &XORMappedAddress{IP: net.ParseIP("192.168.1.32"), Port: 3654})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetXORAddrPort(m, AttrXORMappedAddress); err != nil {
+			b.Fatal(err)
+		}
+	}
+}